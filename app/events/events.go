@@ -0,0 +1,69 @@
+package events
+
+import (
+	"sync"
+	"time"
+)
+
+// Event types published by the controllers.
+const (
+	PhotoLiked     = "photo.liked"
+	PhotoCommented = "photo.commented"
+	CommentReplied = "comment.replied"
+	UserFollowed   = "user.followed"
+
+	PhotoCreated = "photo.created"
+	PhotoUpdated = "photo.updated"
+	PhotoDeleted = "photo.deleted"
+
+	NotificationRecorded = "notification.recorded"
+)
+
+// Event describes something a user did to another user or their content.
+// UserID is the user the event is about, e.g. the owner of the liked photo.
+// Data optionally carries the record the event is about.
+type Event struct {
+	Type      string
+	ActorID   string
+	UserID    string
+	PhotoID   int
+	CommentID int
+	Data      interface{}
+	At        time.Time
+}
+
+// Handler reacts to a published event.
+type Handler func(Event)
+
+// Bus delivers events to its subscribers in the order they subscribed.
+type Bus struct {
+	mu       sync.RWMutex
+	handlers []Handler
+}
+
+// New returns a bus without subscribers.
+func New() *Bus {
+	return &Bus{}
+}
+
+// Subscribe registers h for every event published afterwards.
+func (b *Bus) Subscribe(h Handler) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handlers = append(b.handlers, h)
+}
+
+// Publish hands e to every subscriber before returning.
+func (b *Bus) Publish(e Event) {
+	if e.At.IsZero() {
+		e.At = time.Now()
+	}
+
+	b.mu.RLock()
+	handlers := b.handlers
+	b.mu.RUnlock()
+
+	for _, h := range handlers {
+		h(e)
+	}
+}
@@ -0,0 +1,224 @@
+package remote
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"  // Register the GIF decoder for image verification
+	_ "image/jpeg" // Register the JPEG decoder for image verification
+	_ "image/png"  // Register the PNG decoder for image verification
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Errors returned by Fetch.
+var (
+	ErrScheme       = errors.New("url must use http or https")
+	ErrForbiddenIP  = errors.New("url resolves to a forbidden address")
+	ErrTooLarge     = errors.New("remote file is too large")
+	ErrNotImage     = errors.New("remote file is not a supported image")
+	ErrTooManyHops  = errors.New("too many redirects")
+	ErrRemoteStatus = errors.New("remote server did not return the file")
+)
+
+// Image types accepted from remote servers, with the file extension to store them under.
+var imageTypes = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+// Image is a fetched and verified image.
+type Image struct {
+	Data        []byte
+	ContentType string
+	Ext         string
+}
+
+// Fetcher downloads images from user supplied URLs without reaching internal addresses.
+type Fetcher struct {
+	Timeout      time.Duration
+	MaxBytes     int64
+	MaxRedirects int
+
+	// AllowIP decides which resolved addresses may be dialed. It defaults to PublicIP;
+	// tests replace it to reach an httptest server on loopback.
+	AllowIP func(net.IP) bool
+
+	// Resolver resolves host names itself so the checked address is the one dialed.
+	Resolver *net.Resolver
+}
+
+// New returns a Fetcher with the default limits.
+func New() *Fetcher {
+	return &Fetcher{
+		Timeout:      10 * time.Second,
+		MaxBytes:     10 << 20,
+		MaxRedirects: 3,
+		AllowIP:      PublicIP,
+		Resolver:     net.DefaultResolver,
+	}
+}
+
+// Fetch downloads rawURL and verifies that it is an image.
+func (f *Fetcher) Fetch(ctx context.Context, rawURL string) (*Image, error) {
+	target, err := url.Parse(rawURL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return nil, ErrScheme
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, f.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "image/*")
+
+	resp, err := f.client().Do(req)
+	if err != nil {
+		// Surface our own errors instead of the wrapped url.Error
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			for _, known := range []error{ErrScheme, ErrForbiddenIP, ErrTooManyHops} {
+				if errors.Is(urlErr.Err, known) {
+					return nil, known
+				}
+			}
+		}
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w (status %d)", ErrRemoteStatus, resp.StatusCode)
+	}
+	if resp.ContentLength > f.MaxBytes {
+		return nil, ErrTooLarge
+	}
+
+	// Read one byte past the limit to detect oversized bodies without a Content-Length
+	data, err := io.ReadAll(io.LimitReader(resp.Body, f.MaxBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > f.MaxBytes {
+		return nil, ErrTooLarge
+	}
+
+	return verifyImage(data)
+}
+
+// client builds an HTTP client whose connections only reach allowed addresses.
+func (f *Fetcher) client() *http.Client {
+	dialer := &net.Dialer{Timeout: f.Timeout}
+	transport := &http.Transport{
+		Proxy: nil, // A proxy would resolve the host for us and bypass the address check
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			host, port, err := net.SplitHostPort(addr)
+			if err != nil {
+				return nil, err
+			}
+			addrs, err := f.Resolver.LookupIPAddr(ctx, host)
+			if err != nil {
+				return nil, err
+			}
+			for _, ip := range addrs {
+				if f.AllowIP(ip.IP) {
+					return dialer.DialContext(ctx, network, net.JoinHostPort(ip.IP.String(), port))
+				}
+			}
+			return nil, ErrForbiddenIP
+		},
+		TLSHandshakeTimeout:    f.Timeout,
+		ResponseHeaderTimeout:  f.Timeout,
+		MaxResponseHeaderBytes: 64 << 10,
+	}
+
+	return &http.Client{
+		Transport: transport,
+		Timeout:   f.Timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) > f.MaxRedirects {
+				return ErrTooManyHops
+			}
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return ErrScheme
+			}
+			return nil
+		},
+	}
+}
+
+// verifyImage checks the content of data rather than trusting the remote Content-Type.
+func verifyImage(data []byte) (*Image, error) {
+	contentType := http.DetectContentType(data)
+	ext, ok := imageTypes[contentType]
+	if !ok {
+		return nil, ErrNotImage
+	}
+
+	// Decode the header of the formats the standard library understands
+	if contentType != "image/webp" {
+		if _, _, err := image.DecodeConfig(bytes.NewReader(data)); err != nil {
+			return nil, ErrNotImage
+		}
+	}
+
+	return &Image{Data: data, ContentType: contentType, Ext: ext}, nil
+}
+
+// Address ranges that are not globally reachable beyond what net.IP reports.
+var reservedNets = mustParseCIDRs(
+	"0.0.0.0/8",       // "This" network
+	"100.64.0.0/10",   // Carrier-grade NAT
+	"192.0.0.0/24",    // IETF protocol assignments
+	"192.0.2.0/24",    // Documentation
+	"198.18.0.0/15",   // Benchmarking
+	"198.51.100.0/24", // Documentation
+	"203.0.113.0/24",  // Documentation
+	"240.0.0.0/4",     // Reserved
+	"64:ff9b::/96",    // NAT64, embeds IPv4 addresses
+	"2001:db8::/32",   // Documentation
+)
+
+// PublicIP reports whether ip is a public unicast address.
+func PublicIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return false
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+		if ip4.Equal(net.IPv4bcast) {
+			return false
+		}
+	}
+	for _, n := range reservedNets {
+		if n.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// mustParseCIDRs parses a fixed list of networks.
+func mustParseCIDRs(cidrs ...string) []*net.IPNet {
+	nets := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		nets = append(nets, n)
+	}
+	return nets
+}
@@ -0,0 +1,128 @@
+package remote
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/png"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// loopbackFetcher returns a Fetcher allowed to reach httptest servers, with a small size limit.
+func loopbackFetcher() *Fetcher {
+	f := New()
+	f.MaxBytes = 1 << 10
+	f.AllowIP = func(ip net.IP) bool { return ip.IsLoopback() }
+	return f
+}
+
+// pngData returns a valid 1x1 PNG.
+func pngData(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 1, 1))); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestFetchImage(t *testing.T) {
+	data := pngData(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(data)
+	}))
+	defer server.Close()
+
+	img, err := loopbackFetcher().Fetch(context.Background(), server.URL+"/photo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.ContentType != "image/png" || img.Ext != ".png" || !bytes.Equal(img.Data, data) {
+		t.Errorf("got %s %s with %d bytes, want the PNG", img.ContentType, img.Ext, len(img.Data))
+	}
+}
+
+func TestFetchRefusesLoopback(t *testing.T) {
+	var requested atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested.Store(true)
+	}))
+	defer server.Close()
+
+	_, err := New().Fetch(context.Background(), server.URL)
+	if !errors.Is(err, ErrForbiddenIP) {
+		t.Errorf("got %v, want ErrForbiddenIP", err)
+	}
+	if requested.Load() {
+		t.Error("the loopback server was reached")
+	}
+}
+
+func TestFetchRedirects(t *testing.T) {
+	data := pngData(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// /hops/N redirects N more times before serving the image
+		hops, _ := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/hops/"))
+		if hops > 0 {
+			http.Redirect(w, r, "/hops/"+strconv.Itoa(hops-1), http.StatusFound)
+			return
+		}
+		w.Write(data)
+	}))
+	defer server.Close()
+
+	f := loopbackFetcher()
+	if _, err := f.Fetch(context.Background(), server.URL+"/hops/"+strconv.Itoa(f.MaxRedirects)); err != nil {
+		t.Errorf("%d redirects: got %v, want the image", f.MaxRedirects, err)
+	}
+	_, err := f.Fetch(context.Background(), server.URL+"/hops/"+strconv.Itoa(f.MaxRedirects+1))
+	if !errors.Is(err, ErrTooManyHops) {
+		t.Errorf("%d redirects: got %v, want ErrTooManyHops", f.MaxRedirects+1, err)
+	}
+}
+
+func TestFetchTooLarge(t *testing.T) {
+	f := loopbackFetcher()
+	body := append(pngData(t), make([]byte, f.MaxBytes)...)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/chunked" {
+			// Flushing before the body is complete sends it without a Content-Length
+			w.Write(body[:10])
+			w.(http.Flusher).Flush()
+			w.Write(body[10:])
+			return
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		w.Write(body)
+	}))
+	defer server.Close()
+
+	for _, path := range []string{"/sized", "/chunked"} {
+		_, err := f.Fetch(context.Background(), server.URL+path)
+		if !errors.Is(err, ErrTooLarge) {
+			t.Errorf("%s: got %v, want ErrTooLarge", path, err)
+		}
+	}
+}
+
+func TestFetchNotImage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The Content-Type is not trusted, the body is checked
+		w.Header().Set("Content-Type", "image/png")
+		w.Write([]byte("<html><body>not an image</body></html>"))
+	}))
+	defer server.Close()
+
+	_, err := loopbackFetcher().Fetch(context.Background(), server.URL)
+	if !errors.Is(err, ErrNotImage) {
+		t.Errorf("got %v, want ErrNotImage", err)
+	}
+}
@@ -0,0 +1,96 @@
+package search
+
+import (
+	"html"
+	"strings"
+	"unicode"
+)
+
+// Snippet markers wrapped around matching terms.
+const (
+	MarkOpen  = "<mark>"
+	MarkClose = "</mark>"
+)
+
+// Highlight returns an HTML-escaped excerpt of text around the first query match,
+// with every matching word wrapped in <mark>. It returns an empty string when nothing matches.
+func Highlight(text string, query string, width int) string {
+	terms := map[string]bool{}
+	for _, term := range Tokenize(query) {
+		terms[term] = true
+	}
+
+	// Find the words of the text with their byte offsets
+	type word struct{ start, end int }
+	words := []word{}
+	start := -1
+	for i, r := range text {
+		inWord := unicode.IsLetter(r) || unicode.IsDigit(r)
+		if inWord && start < 0 {
+			start = i
+		} else if !inWord && start >= 0 {
+			words = append(words, word{start, i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		words = append(words, word{start, len(text)})
+	}
+
+	matches := []word{}
+	for _, w := range words {
+		if terms[strings.ToLower(text[w.start:w.end])] {
+			matches = append(matches, w)
+		}
+	}
+	if len(matches) == 0 {
+		return ""
+	}
+
+	// Center the excerpt on the first match
+	from, to := 0, len(text)
+	if width > 0 && len(text) > width {
+		from = matches[0].start - width/3
+		if from < 0 {
+			from = 0
+		}
+		to = from + width
+		if to > len(text) {
+			to = len(text)
+		}
+		from, to = wordBoundary(text, from, false), wordBoundary(text, to, true)
+	}
+
+	var sb strings.Builder
+	if from > 0 {
+		sb.WriteString("…")
+	}
+	pos := from
+	for _, m := range matches {
+		if m.start < from || m.end > to {
+			continue
+		}
+		sb.WriteString(html.EscapeString(text[pos:m.start]))
+		sb.WriteString(MarkOpen + html.EscapeString(text[m.start:m.end]) + MarkClose)
+		pos = m.end
+	}
+	sb.WriteString(html.EscapeString(text[pos:to]))
+	if to < len(text) {
+		sb.WriteString("…")
+	}
+	return sb.String()
+}
+
+// wordBoundary moves i to the nearest space so the excerpt doesn't cut words.
+func wordBoundary(text string, i int, forward bool) int {
+	if forward {
+		if j := strings.IndexByte(text[i:], ' '); j >= 0 {
+			return i + j
+		}
+		return len(text)
+	}
+	if j := strings.LastIndexByte(text[:i], ' '); j >= 0 {
+		return j + 1
+	}
+	return 0
+}
@@ -0,0 +1,28 @@
+package search
+
+import "testing"
+
+func TestHighlight(t *testing.T) {
+	cases := []struct {
+		name  string
+		text  string
+		query string
+		width int
+		want  string
+	}{
+		{"single term", "Sunset over the harbour", "harbour", 0, "Sunset over the <mark>harbour</mark>"},
+		{"any case", "Harbour lights", "HARBOUR", 0, "<mark>Harbour</mark> lights"},
+		{"every term", "Sunset over the harbour", "harbour sunset", 0, "<mark>Sunset</mark> over the <mark>harbour</mark>"},
+		{"whole words only", "Harbours", "harbour", 0, ""},
+		{"no match", "Sunset over the harbour", "zebra", 0, ""},
+		{"html is escaped", "Fish & <b>chips</b>", "chips", 0, "Fish &amp; &lt;b&gt;<mark>chips</mark>&lt;/b&gt;"},
+		{"excerpt around the match", "one two three four five six seven eight nine ten eleven twelve", "eight", 20, "…seven <mark>eight</mark> nine ten…"},
+		{"excerpt from the start", "eight two three four five six seven", "eight", 20, "<mark>eight</mark> two three four…"},
+		{"short text is kept whole", "Sunset over the harbour", "sunset", 160, "<mark>Sunset</mark> over the harbour"},
+	}
+	for _, tc := range cases {
+		if got := Highlight(tc.text, tc.query, tc.width); got != tc.want {
+			t.Errorf("%s: got %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
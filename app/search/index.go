@@ -0,0 +1,150 @@
+package search
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// BM25 tuning parameters.
+const (
+	k1 = 1.2
+	b  = 0.75
+)
+
+// Hit is a matching document with its relevance score.
+type Hit struct {
+	ID    int
+	Score float64
+}
+
+// Index is an in-memory inverted index used when the database has no native full-text search.
+type Index struct {
+	mu       sync.RWMutex
+	ready    bool
+	postings map[string]map[int]int // term -> document -> term frequency
+	lengths  map[int]int            // document -> number of terms
+	total    int                    // sum of all document lengths
+}
+
+// NewIndex returns an empty index.
+func NewIndex() *Index {
+	return &Index{
+		postings: map[string]map[int]int{},
+		lengths:  map[int]int{},
+	}
+}
+
+// Ready reports whether the index has been loaded and is kept up to date.
+func (idx *Index) Ready() bool {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return idx.ready
+}
+
+// MarkReady records that the index holds every document.
+func (idx *Index) MarkReady() {
+	idx.mu.Lock()
+	idx.ready = true
+	idx.mu.Unlock()
+}
+
+// Add indexes a document, replacing a previous version with the same ID.
+func (idx *Index) Add(id int, fields ...string) {
+	terms := Tokenize(strings.Join(fields, " "))
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.remove(id)
+	for _, term := range terms {
+		docs := idx.postings[term]
+		if docs == nil {
+			docs = map[int]int{}
+			idx.postings[term] = docs
+		}
+		docs[id]++
+	}
+	idx.lengths[id] = len(terms)
+	idx.total += len(terms)
+}
+
+// Remove drops a document from the index.
+func (idx *Index) Remove(id int) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.remove(id)
+}
+
+// remove drops a document; the caller holds the write lock.
+func (idx *Index) remove(id int) {
+	length, ok := idx.lengths[id]
+	if !ok {
+		return
+	}
+	for term, docs := range idx.postings {
+		if _, ok := docs[id]; ok {
+			delete(docs, id)
+			if len(docs) == 0 {
+				delete(idx.postings, term)
+			}
+		}
+	}
+	delete(idx.lengths, id)
+	idx.total -= length
+}
+
+// Search returns the documents matching any query term, best first, ranked with BM25.
+func (idx *Index) Search(query string, limit int) []Hit {
+	terms := Tokenize(query)
+
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	if len(idx.lengths) == 0 || len(terms) == 0 {
+		return nil
+	}
+
+	n := float64(len(idx.lengths))
+	avg := float64(idx.total) / n
+	scores := map[int]float64{}
+	seen := map[string]bool{}
+	for _, term := range terms {
+		if seen[term] {
+			continue
+		}
+		seen[term] = true
+
+		docs := idx.postings[term]
+		if len(docs) == 0 {
+			continue
+		}
+		idf := math.Log(1 + (n-float64(len(docs))+0.5)/(float64(len(docs))+0.5))
+		for id, tf := range docs {
+			norm := k1 * (1 - b + b*float64(idx.lengths[id])/avg)
+			scores[id] += idf * float64(tf) * (k1 + 1) / (float64(tf) + norm)
+		}
+	}
+
+	hits := make([]Hit, 0, len(scores))
+	for id, score := range scores {
+		hits = append(hits, Hit{ID: id, Score: score})
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].ID > hits[j].ID
+	})
+	if limit > 0 && len(hits) > limit {
+		hits = hits[:limit]
+	}
+	return hits
+}
+
+// Tokenize lowercases text and splits it into letter and digit runs.
+func Tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
@@ -0,0 +1,86 @@
+package search
+
+import (
+	"math"
+	"slices"
+	"testing"
+)
+
+// ids returns the document IDs of hits in order.
+func ids(hits []Hit) []int {
+	out := make([]int, len(hits))
+	for i, hit := range hits {
+		out[i] = hit.ID
+	}
+	return out
+}
+
+func TestSearchScore(t *testing.T) {
+	idx := NewIndex()
+	idx.Add(1, "a b")
+	idx.Add(2, "b c")
+
+	// With one of two documents matching and both of average length, BM25 gives ln(1 + 1.5/1.5) * 2.2/2.2
+	hits := idx.Search("a", 0)
+	if len(hits) != 1 || hits[0].ID != 1 || math.Abs(hits[0].Score-math.Ln2) > 1e-9 {
+		t.Errorf("got %v, want document 1 scored ln 2", hits)
+	}
+}
+
+func TestSearchRanking(t *testing.T) {
+	idx := NewIndex()
+	idx.Add(1, "Sunset over the harbour")
+	idx.Add(2, "Harbour, harbour", "boats")
+	idx.Add(3, "Mountain sunset")
+	idx.Add(4, "A long walk along the quiet harbour", "at the end of a summer day")
+	idx.Add(5, "Lighthouse")
+
+	cases := []struct {
+		name  string
+		query string
+		limit int
+		want  []int
+	}{
+		{"more occurrences, then shorter documents first", "harbour", 0, []int{2, 1, 4}},
+		{"case and punctuation are ignored", "HARBOUR!", 0, []int{2, 1, 4}},
+		{"limit", "harbour", 2, []int{2, 1}},
+		{"every term matching first", "sunset harbour", 1, []int{1}},
+		{"rarer terms weigh more", "lighthouse sunset", 0, []int{5, 3, 1}},
+		{"no match", "zebra", 0, []int{}},
+		{"no terms", "  ?! ", 0, []int{}},
+	}
+	for _, tc := range cases {
+		if got := ids(idx.Search(tc.query, tc.limit)); !slices.Equal(got, tc.want) {
+			t.Errorf("%s: %q got %v, want %v", tc.name, tc.query, got, tc.want)
+		}
+	}
+}
+
+func TestSearchTies(t *testing.T) {
+	idx := NewIndex()
+	idx.Add(1, "harbour")
+	idx.Add(2, "harbour")
+	if got := ids(idx.Search("harbour", 0)); !slices.Equal(got, []int{2, 1}) {
+		t.Errorf("got %v, want the newer document first", got)
+	}
+}
+
+func TestIndexReplaceAndRemove(t *testing.T) {
+	idx := NewIndex()
+	idx.Add(1, "Sunset over the harbour")
+	idx.Add(2, "Harbour boats")
+
+	idx.Add(1, "Ocean")
+	if got := ids(idx.Search("sunset", 0)); len(got) != 0 {
+		t.Errorf("replaced document: got %v for its old words", got)
+	}
+	if got := ids(idx.Search("ocean", 0)); !slices.Equal(got, []int{1}) {
+		t.Errorf("replaced document: got %v for its new words, want [1]", got)
+	}
+
+	idx.Remove(2)
+	idx.Remove(3) // Removing a missing document is harmless
+	if got := ids(idx.Search("harbour boats", 0)); len(got) != 0 {
+		t.Errorf("removed document: got %v", got)
+	}
+}
@@ -0,0 +1,82 @@
+package storage
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultURLTTL is how long a signed file URL stays valid when FILE_URL_TTL is unset.
+const DefaultURLTTL = 15 * time.Minute
+
+// ErrNoURLKey is returned when neither FILE_URL_SECRET nor API_SECRET is set.
+// File URLs signed with an empty key could be forged by anyone, so none are accepted then.
+var ErrNoURLKey = errors.New("FILE_URL_SECRET or API_SECRET must be set to sign file urls")
+
+// urlKey returns the key used to sign file URLs.
+func urlKey() []byte {
+	if key := os.Getenv("FILE_URL_SECRET"); key != "" {
+		return []byte(key)
+	}
+	return []byte(os.Getenv("API_SECRET"))
+}
+
+// CheckURLKey reports whether a key to sign file URLs is configured.
+func CheckURLKey() error {
+	if len(urlKey()) == 0 {
+		return ErrNoURLKey
+	}
+	return nil
+}
+
+// URLTTL returns the configured lifetime of signed file URLs.
+func URLTTL() time.Duration {
+	if ttl, err := time.ParseDuration(os.Getenv("FILE_URL_TTL")); err == nil && ttl > 0 {
+		return ttl
+	}
+	return DefaultURLTTL
+}
+
+// signature computes the HMAC of a key and its expiry.
+func signature(key string, expires int64) string {
+	mac := hmac.New(sha256.New, urlKey())
+	mac.Write([]byte(key + "\n" + strconv.FormatInt(expires, 10)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// SignURL returns a /files URL for the object that expires after ttl.
+// The expiry is rounded up to the minute, so the URL, and responses holding it, stay the same for a while.
+func SignURL(key string, ttl time.Duration) string {
+	expires := time.Now().Add(ttl).Add(time.Minute - 1).Truncate(time.Minute).Unix()
+	query := url.Values{}
+	query.Set("expires", strconv.FormatInt(expires, 10))
+	query.Set("signature", signature(key, expires))
+
+	base := strings.TrimSuffix(os.Getenv("APP_URL"), "/")
+	return base + "/files/" + strings.TrimPrefix(key, "/") + "?" + query.Encode()
+}
+
+// Verify checks the signature of a file URL and returns its expiry time.
+func Verify(key string, expires string, sig string) (time.Time, error) {
+	if err := CheckURLKey(); err != nil {
+		return time.Time{}, err
+	}
+	unix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return time.Time{}, errors.New("file url is invalid")
+	}
+	if !hmac.Equal([]byte(sig), []byte(signature(key, unix))) {
+		return time.Time{}, errors.New("file url signature is invalid")
+	}
+	expiresAt := time.Unix(unix, 0)
+	if time.Now().After(expiresAt) {
+		return time.Time{}, errors.New("file url has expired")
+	}
+	return expiresAt, nil
+}
@@ -0,0 +1,88 @@
+package storage
+
+import (
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// signedQuery signs key and returns the expires and signature values of the URL.
+func signedQuery(t *testing.T, key string, ttl time.Duration) (string, string) {
+	t.Helper()
+	signed, err := url.Parse(SignURL(key, ttl))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "/files/" + key; signed.Path != want {
+		t.Fatalf("got path %s, want %s", signed.Path, want)
+	}
+	return signed.Query().Get("expires"), signed.Query().Get("signature")
+}
+
+func TestVerifySignedURL(t *testing.T) {
+	t.Setenv("FILE_URL_SECRET", "secret")
+	expires, sig := signedQuery(t, "photos/1.png", time.Minute)
+
+	expiresAt, err := Verify("photos/1.png", expires, sig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if until := time.Until(expiresAt); until <= time.Minute || until > 2*time.Minute {
+		t.Errorf("got expiry in %v, want the ttl rounded up to the minute", until)
+	}
+
+	cases := []struct {
+		name    string
+		key     string
+		expires string
+		sig     string
+	}{
+		{"other key", "photos/2.png", expires, sig},
+		{"later expiry", "photos/1.png", expires + "0", sig},
+		{"bad expiry", "photos/1.png", "soon", sig},
+		{"changed signature", "photos/1.png", expires, strings.Repeat("0", len(sig))},
+		{"no signature", "photos/1.png", expires, ""},
+	}
+	for _, tc := range cases {
+		if _, err := Verify(tc.key, tc.expires, tc.sig); err == nil {
+			t.Errorf("%s: the url was accepted", tc.name)
+		}
+	}
+
+	// Another secret doesn't accept the signature
+	t.Setenv("FILE_URL_SECRET", "other")
+	if _, err := Verify("photos/1.png", expires, sig); err == nil {
+		t.Error("the url was accepted with another secret")
+	}
+}
+
+func TestVerifyExpired(t *testing.T) {
+	t.Setenv("FILE_URL_SECRET", "secret")
+	past := time.Now().Add(-time.Minute).Unix()
+	_, err := Verify("photos/1.png", strconv.FormatInt(past, 10), signature("photos/1.png", past))
+	if err == nil || !strings.Contains(err.Error(), "expired") {
+		t.Errorf("got %v, want the url expired", err)
+	}
+}
+
+func TestVerifyWithoutKey(t *testing.T) {
+	t.Setenv("FILE_URL_SECRET", "")
+	t.Setenv("API_SECRET", "")
+	if err := CheckURLKey(); !errors.Is(err, ErrNoURLKey) {
+		t.Errorf("got %v, want ErrNoURLKey", err)
+	}
+
+	// A URL signed with the empty key is what anyone could forge
+	expires, sig := signedQuery(t, "photos/1.png", time.Minute)
+	if _, err := Verify("photos/1.png", expires, sig); !errors.Is(err, ErrNoURLKey) {
+		t.Errorf("got %v, want ErrNoURLKey", err)
+	}
+
+	t.Setenv("API_SECRET", "secret")
+	if err := CheckURLKey(); err != nil {
+		t.Errorf("API_SECRET set: got %v", err)
+	}
+}
@@ -0,0 +1,117 @@
+package storage
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ErrInvalidKey is returned for keys that would escape the storage root.
+var ErrInvalidKey = errors.New("invalid object key")
+
+// Object is a stored file opened for reading.
+type Object struct {
+	io.ReadSeekCloser
+	Size        int64
+	ModTime     time.Time
+	ContentType string
+}
+
+// Storage stores photo files by key.
+type Storage interface {
+	Put(key string, r io.Reader) (int64, error)
+	Open(key string) (*Object, error)
+	Delete(key string) error
+}
+
+// Local stores objects as files below a root directory.
+type Local struct {
+	Root string
+}
+
+// Default returns the storage configured by STORAGE_DIR, "uploads" when unset.
+func Default() Storage {
+	root := os.Getenv("STORAGE_DIR")
+	if root == "" {
+		root = "uploads"
+	}
+	return &Local{Root: root}
+}
+
+// path maps a key to a file below the root.
+func (l *Local) path(key string) (string, error) {
+	clean := path.Clean("/" + key)
+	if clean == "/" || strings.Contains(key, "..") {
+		return "", ErrInvalidKey
+	}
+	return filepath.Join(l.Root, filepath.FromSlash(clean)), nil
+}
+
+// Put writes the object, replacing any existing one.
+func (l *Local) Put(key string, r io.Reader) (int64, error) {
+	name, err := l.path(key)
+	if err != nil {
+		return 0, err
+	}
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return 0, err
+	}
+
+	// Write to a temporary file first so readers never see a partial object
+	tmp, err := os.CreateTemp(filepath.Dir(name), ".upload-*")
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(tmp, r)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), name)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return 0, err
+	}
+	return n, nil
+}
+
+// Open opens the object for reading.
+func (l *Local) Open(key string) (*Object, error) {
+	name, err := l.path(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		f.Close()
+		return nil, os.ErrNotExist
+	}
+	return &Object{
+		ReadSeekCloser: f,
+		Size:           info.Size(),
+		ModTime:        info.ModTime(),
+		ContentType:    mime.TypeByExtension(filepath.Ext(name)),
+	}, nil
+}
+
+// Delete removes the object. Deleting a missing object is not an error.
+func (l *Local) Delete(key string) error {
+	name, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(name); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package stream
+
+import (
+	"encoding/json"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Message is a single event sent to the subscribers of a Hub.
+type Message struct {
+	ID    uint64
+	Event string
+	Data  []byte
+
+	audience map[string]bool // nil for everyone
+}
+
+// Subscriber receives the messages published for one user.
+// C is closed when the subscriber is removed, including when it falls too far behind.
+type Subscriber struct {
+	UserID string
+	C      chan Message
+
+	evicted bool
+}
+
+// Evicted reports whether the subscriber was dropped for not keeping up.
+func (s *Subscriber) Evicted() bool {
+	return s.evicted
+}
+
+// Hub fans published messages out to subscribers and keeps recent ones for resuming.
+type Hub struct {
+	// Heartbeat is how often streams should send a keep-alive comment.
+	Heartbeat time.Duration
+
+	mu          sync.Mutex
+	nextID      uint64
+	bufferSize  int
+	historySize int
+	history     []Message
+	subscribers map[*Subscriber]bool
+}
+
+// NewHub returns a hub buffering bufferSize messages per subscriber and keeping historySize messages for resuming.
+func NewHub(bufferSize, historySize int) *Hub {
+	return &Hub{
+		Heartbeat:   15 * time.Second,
+		bufferSize:  bufferSize,
+		historySize: historySize,
+		subscribers: map[*Subscriber]bool{},
+	}
+}
+
+// Publish sends data as JSON to the given users, or to everyone when no user is given.
+// Subscribers whose buffer is full are evicted instead of blocking the publisher.
+func (h *Hub) Publish(event string, data interface{}, userIDs ...string) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	msg := Message{Event: event, Data: payload}
+	if len(userIDs) > 0 {
+		msg.audience = make(map[string]bool, len(userIDs))
+		for _, id := range userIDs {
+			msg.audience[id] = true
+		}
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.nextID++
+	msg.ID = h.nextID
+	h.history = append(h.history, msg)
+	if len(h.history) > h.historySize {
+		h.history = h.history[len(h.history)-h.historySize:]
+	}
+
+	for s := range h.subscribers {
+		if !msg.For(s.UserID) {
+			continue
+		}
+		select {
+		case s.C <- msg:
+		default:
+			// A slow consumer is dropped; it can reconnect with Last-Event-ID
+			s.evicted = true
+			h.remove(s)
+		}
+	}
+	return nil
+}
+
+// Subscribe registers a subscriber for userID.
+// Messages published after lastEventID that are still kept are returned for replay,
+// so nothing is lost or sent twice between the replay and the live messages.
+func (h *Hub) Subscribe(userID string, lastEventID string) (*Subscriber, []Message) {
+	s := &Subscriber{UserID: userID, C: make(chan Message, h.bufferSize)}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var replay []Message
+	if last, err := strconv.ParseUint(lastEventID, 10, 64); err == nil && last <= h.nextID {
+		for _, msg := range h.history {
+			if msg.ID > last && msg.For(userID) {
+				replay = append(replay, msg)
+			}
+		}
+	}
+
+	h.subscribers[s] = true
+	return s, replay
+}
+
+// Unsubscribe removes the subscriber and closes its channel. It is safe to call more than once.
+func (h *Hub) Unsubscribe(s *Subscriber) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.remove(s)
+}
+
+func (h *Hub) remove(s *Subscriber) {
+	if h.subscribers[s] {
+		delete(h.subscribers, s)
+		close(s.C)
+	}
+}
+
+// For reports whether the message is meant for userID.
+func (m Message) For(userID string) bool {
+	return m.audience == nil || m.audience[userID]
+}
@@ -0,0 +1,123 @@
+package stream
+
+import (
+	"slices"
+	"testing"
+)
+
+// drain returns the data of the messages waiting on s, without blocking.
+func drain(s *Subscriber) []string {
+	var got []string
+	for {
+		select {
+		case msg, ok := <-s.C:
+			if !ok {
+				return got
+			}
+			got = append(got, string(msg.Data))
+		default:
+			return got
+		}
+	}
+}
+
+func TestPublishAudience(t *testing.T) {
+	hub := NewHub(8, 8)
+	alice, _ := hub.Subscribe("alice", "")
+	bob, _ := hub.Subscribe("bob", "")
+
+	hub.Publish("photo", 1)
+	hub.Publish("photo", 2, "alice")
+	hub.Publish("photo", 3, "bob", "carol")
+
+	if got := drain(alice); len(got) != 2 || got[0] != "1" || got[1] != "2" {
+		t.Errorf("alice got %v, want [1 2]", got)
+	}
+	if got := drain(bob); len(got) != 2 || got[0] != "1" || got[1] != "3" {
+		t.Errorf("bob got %v, want [1 3]", got)
+	}
+}
+
+func TestSlowSubscriberEvicted(t *testing.T) {
+	hub := NewHub(2, 8)
+	slow, _ := hub.Subscribe("alice", "")
+	other, _ := hub.Subscribe("bob", "")
+
+	for i := 1; i <= 3; i++ {
+		if err := hub.Publish("photo", i, "alice"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if !slow.Evicted() {
+		t.Fatal("a subscriber with a full buffer was not evicted")
+	}
+	// The buffered messages are still delivered before the channel reports it is closed
+	if got := drain(slow); len(got) != 2 || got[0] != "1" || got[1] != "2" {
+		t.Errorf("evicted subscriber got %v, want [1 2]", got)
+	}
+	if _, ok := <-slow.C; ok {
+		t.Error("the channel of an evicted subscriber is still open")
+	}
+	if other.Evicted() {
+		t.Error("a subscriber without messages for it was evicted")
+	}
+
+	// Unsubscribing after the eviction must not close the channel twice
+	hub.Unsubscribe(slow)
+	hub.Publish("photo", 4)
+	if got := drain(other); len(got) != 1 || got[0] != "4" {
+		t.Errorf("remaining subscriber got %v, want [4]", got)
+	}
+}
+
+func TestSubscribeReplay(t *testing.T) {
+	hub := NewHub(8, 3)
+	for i := 1; i <= 5; i++ {
+		to := "alice"
+		if i == 4 {
+			to = "bob"
+		}
+		hub.Publish("photo", i, to)
+	}
+	// Kept are messages 3, 4 (for bob) and 5
+
+	tests := []struct {
+		name        string
+		lastEventID string
+		want        []uint64
+	}{
+		{"without Last-Event-ID", "", nil},
+		{"unparsable Last-Event-ID", "abc", nil},
+		{"after the newest message", "5", nil},
+		{"from a future id", "9", nil},
+		{"after a kept message", "3", []uint64{5}},
+		{"after an expired message", "1", []uint64{3, 5}},
+	}
+	for _, tt := range tests {
+		s, replay := hub.Subscribe("alice", tt.lastEventID)
+		var got []uint64
+		for _, msg := range replay {
+			got = append(got, msg.ID)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s: replayed %v, want %v", tt.name, got, tt.want)
+		}
+		hub.Unsubscribe(s)
+	}
+
+	// A message published after subscribing arrives live only, not in the replay as well
+	s, replay := hub.Subscribe("alice", "5")
+	hub.Publish("photo", 6, "alice")
+	if len(replay) != 0 {
+		t.Errorf("replayed %d messages, want none", len(replay))
+	}
+	select {
+	case msg := <-s.C:
+		if msg.ID != 6 || string(msg.Data) != "6" {
+			t.Errorf("got live message %d %s, want 6", msg.ID, msg.Data)
+		}
+	default:
+		t.Error("the message published after subscribing was not delivered")
+	}
+}
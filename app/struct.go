@@ -8,10 +8,10 @@ type Photo struct {
 	PhotoUrl string `json:"photo_url"`
 }
 
+// Owner is the public identity of a user; it leaves out the email, which only the user sees.
 type Owner struct {
 	ID       string `json:"id"`
 	Username string `json:"username"`
-	Email    string `json:"email"`
 }
 
 type UserData struct {
@@ -22,12 +22,78 @@ type UserData struct {
 	Token    string `json:"token"`
 }
 
+type UserInput struct {
+	Username string `json:"username" validate:"notblank,escapedmax=255"`
+	Email    string `json:"email" validate:"required,email,escapedmax=255"`
+	Password string `json:"password" validate:"required,password"`
+	Locale   string `json:"locale" validate:"omitempty,locale"`
+}
+
+type UserPatch struct {
+	Username string `json:"username" validate:"notblank,escapedmax=255"`
+	Email    string `json:"email" validate:"required,email,escapedmax=255"`
+	Locale   string `json:"locale" validate:"omitempty,locale"`
+	Password string `json:"password,omitempty" validate:"isdefault"`
+}
+
+type PasswordInput struct {
+	CurrentPassword string `json:"current_password" validate:"required"`
+	NewPassword     string `json:"new_password" validate:"required,password"`
+}
+
+type LoginInput struct {
+	Email    string `json:"email" validate:"required,email"`
+	Password string `json:"password" validate:"required"`
+}
+
+type PhotoInput struct {
+	Title      string   `json:"title" validate:"notblank,escapedmax=255"`
+	Caption    string   `json:"caption" validate:"notblank,escapedmax=255"`
+	PhotoUrl   string   `json:"photo_url" validate:"required_without=SourceURL,omitempty,httpurl,escapedmax=255"`
+	SourceURL  string   `json:"source_url" validate:"omitempty,httpurl,escapedmax=255"`
+	Visibility string   `json:"visibility" validate:"omitempty,oneof=public unlisted private followers"`
+	Tags       []string `json:"tags"`
+}
+
+type AlbumInput struct {
+	Title        string `json:"title" validate:"notblank,escapedmax=255"`
+	Description  string `json:"description" validate:"escapedmax=255"`
+	CoverPhotoID *int   `json:"cover_photo_id"`
+	Visibility   string `json:"visibility" validate:"omitempty,oneof=public unlisted private"`
+}
+
+type UserStats struct {
+	PhotoCount     int `json:"photo_count"`
+	AlbumCount     int `json:"album_count"`
+	FollowerCount  int `json:"follower_count"`
+	FollowingCount int `json:"following_count"`
+}
+
+type UserSelf struct {
+	ID           string    `json:"id"`
+	Username     string    `json:"username"`
+	Email        string    `json:"email"`
+	Locale       string    `json:"locale"`
+	PrimaryPhoto *Photo    `json:"primary_photo"`
+	Stats        UserStats `json:"stats"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+type UserPublic struct {
+	ID           string    `json:"id"`
+	Username     string    `json:"username"`
+	PrimaryPhoto *Photo    `json:"primary_photo"`
+	Stats        UserStats `json:"stats"`
+}
+
 type UserLogin struct {
 	ID       string `json:"id"`
 	Username string `json:"users.username"`
 	Email    string `json:"users.email"`
 	Token    string `json:"users.token"`
 	Password string `json:"users.password"`
+	Locale   string `json:"users.locale"`
 	Title    string `json:"photos.title"`
 	Caption  string `json:"photos.caption"`
 	PhotoUrl string `json:"photos.photo_url"`
@@ -37,6 +103,33 @@ type UserRegister struct {
 	ID        string    `json:"id"`
 	Username  string    `json:"username"`
 	Email     string    `json:"email"`
+	Locale    string    `json:"locale"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
-}
\ No newline at end of file
+}
+
+type AlbumPhotoInput struct {
+	PhotoID  int  `json:"photo_id" validate:"required"`
+	Position *int `json:"position" validate:"omitempty,min=0"`
+}
+
+type AlbumPhotoOrder struct {
+	PhotoIDs []int `json:"photo_ids" validate:"required"`
+}
+
+type Liker struct {
+	ID       string    `json:"id"`
+	Username string    `json:"username"`
+	LikedAt  time.Time `json:"liked_at"`
+}
+
+type FollowUser struct {
+	ID         string    `json:"id"`
+	Username   string    `json:"username"`
+	FollowedAt time.Time `json:"followed_at"`
+}
+
+type CommentInput struct {
+	Body     string `json:"body" validate:"notblank,max=1000"`
+	ParentID *int   `json:"parent_id"`
+}
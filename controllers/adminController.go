@@ -0,0 +1,132 @@
+package controllers
+
+import (
+	"net/http"
+
+	"task-5-pbi-btpns-arthagusfiputra/app"
+	"task-5-pbi-btpns-arthagusfiputra/helpers/apperror"
+	"task-5-pbi-btpns-arthagusfiputra/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+)
+
+// RestoreUser brings back a deleted user together with the photos deleted with them. Administrators only.
+func RestoreUser(c *gin.Context) {
+	// Set the database
+	db := c.MustGet("db").(*gorm.DB)
+
+	if _, ok := currentAdmin(c, db); !ok {
+		return
+	}
+
+	// Check if the user exists and is deleted
+	var user models.User
+	if err := db.Unscoped().Where("id = ? AND deleted_at IS NOT NULL", c.Param("userId")).First(&user).Error; err != nil {
+		fail(c, apperror.New(apperror.UserNotFound).With("id", c.Param("userId")))
+		return
+	}
+
+	// Restore the photos deleted at the same moment as the user, then the user; their tags and likes count again
+	photoIDs := []int{}
+	err := inTransaction(db, func(tx *gorm.DB) error {
+		photoIDs = photoIDs[:0]
+		if err := tx.Unscoped().Model(&models.Photo{}).Where("user_id = ? AND deleted_at = ?", user.ID, *user.DeletedAt).
+			Pluck("id", &photoIDs).Error; err != nil {
+			return err
+		}
+		if len(photoIDs) > 0 {
+			if err := tx.Unscoped().Model(&models.Photo{}).Where("id IN (?)", photoIDs).UpdateColumn("deleted_at", nil).Error; err != nil {
+				return err
+			}
+		}
+		if err := countPhotoTags(tx, photoIDs, 1); err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Model(&user).UpdateColumn("deleted_at", nil).Error; err != nil {
+			return err
+		}
+		return recountLikes(tx, user.ID)
+	})
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	// UpdateColumn skips the hooks that keep the search index in step
+	photos := []models.Photo{}
+	if len(photoIDs) > 0 {
+		if err := db.Where("id IN (?)", photoIDs).Find(&photos).Error; err != nil {
+			fail(c, err)
+			return
+		}
+	}
+	for i := range photos {
+		photos[i].Index()
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "Success",
+		"message": translate(c, "USER_RESTORED"),
+		"data": app.UserRegister{
+			ID:        user.ID,
+			Username:  user.Username,
+			Email:     user.Email,
+			Locale:    user.Locale,
+			CreatedAt: user.CreatedAt,
+			UpdatedAt: user.UpdatedAt,
+		},
+	})
+}
+
+// RestorePhoto brings back a deleted photo whose owner still exists. Administrators only.
+func RestorePhoto(c *gin.Context) {
+	// Set the database
+	db := c.MustGet("db").(*gorm.DB)
+
+	admin, ok := currentAdmin(c, db)
+	if !ok {
+		return
+	}
+
+	// Check if the photo exists and is deleted
+	var photo models.Photo
+	if err := db.Unscoped().Where("id = ? AND deleted_at IS NOT NULL", c.Param("photoId")).First(&photo).Error; err != nil {
+		fail(c, apperror.New(apperror.PhotoNotFound).With("id", c.Param("photoId")))
+		return
+	}
+
+	// The photos of a deleted user come back with the user
+	if err := db.Where("id = ?", photo.UserID).First(&models.User{}).Error; err != nil {
+		fail(c, apperror.Keyed(apperror.Conflict, "PHOTO_OWNER_DELETED"))
+		return
+	}
+
+	// Restore the photo, its tags count again
+	err := inTransaction(db, func(tx *gorm.DB) error {
+		if err := countPhotoTags(tx, []int{photo.ID}, 1); err != nil {
+			return err
+		}
+		return tx.Unscoped().Model(&photo).Update("deleted_at", nil).Error
+	})
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	photos := []models.Photo{photo}
+	err = attachOwners(db, photos)
+	if err == nil {
+		err = presentPhotos(db, photos, admin.ID)
+	}
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "Success",
+		"message": translate(c, "PHOTO_RESTORED"),
+		"data":    photos[0],
+	})
+}
@@ -0,0 +1,56 @@
+package controllers_test
+
+import (
+	"net/http"
+	"testing"
+
+	"task-5-pbi-btpns-arthagusfiputra/models"
+)
+
+// TestRestoreUserSearch checks that the photos of a deleted user leave the in-memory search index and come back with the user.
+func TestRestoreUserSearch(t *testing.T) {
+	h, db := newServer(t)
+	freshSearchIndex(t)
+	alice, aliceToken := seedUser(t, db, "alice")
+	photo := seedPhoto(t, db, alice)
+	if err := db.Model(&photo).Update("title", "Sunset over the harbour").Error; err != nil {
+		t.Fatal(err)
+	}
+	admin, adminToken := seedUser(t, db, "admin")
+	if err := db.Model(&admin).UpdateColumn("admin", true).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	hits := func() int {
+		code, body := call(h, http.MethodGet, "/search/photos?q=harbour", "", nil)
+		if code != http.StatusOK {
+			t.Fatalf("search: got status %d: %v", code, body)
+		}
+		return len(body["data"].([]interface{}))
+	}
+	// The search drops deleted photos when it reads them back, so the index itself is checked too
+	indexed := func() bool {
+		for _, hit := range models.PhotoIndex.Search("harbour", 10) {
+			if hit.ID == photo.ID {
+				return true
+			}
+		}
+		return false
+	}
+
+	if n := hits(); n != 1 {
+		t.Fatalf("before the delete: got %d hits, want 1", n)
+	}
+	if code, body := call(h, http.MethodDelete, "/users/"+alice.ID, aliceToken, nil); code != http.StatusOK {
+		t.Fatalf("delete: got status %d: %v", code, body)
+	}
+	if n := hits(); n != 0 || indexed() {
+		t.Errorf("after the delete: got %d hits and indexed %v, want 0 and false", n, indexed())
+	}
+	if code, body := call(h, http.MethodPost, "/admin/users/"+alice.ID+"/restore", adminToken, nil); code != http.StatusOK {
+		t.Fatalf("restore: got status %d: %v", code, body)
+	}
+	if n := hits(); n != 1 || !indexed() {
+		t.Errorf("after the restore: got %d hits and indexed %v, want 1 and true", n, indexed())
+	}
+}
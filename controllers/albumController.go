@@ -0,0 +1,503 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"task-5-pbi-btpns-arthagusfiputra/app"
+	"task-5-pbi-btpns-arthagusfiputra/helpers/apperror"
+	"task-5-pbi-btpns-arthagusfiputra/helpers/pagination"
+	"task-5-pbi-btpns-arthagusfiputra/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+)
+
+// GetAlbums retrieves the public albums, newest first, a page at a time.
+func GetAlbums(c *gin.Context) {
+	// Set the database
+	db := c.MustGet("db").(*gorm.DB)
+
+	query := db.Scopes(activeUsers("user_id")).Where("visibility = ?", models.VisibilityPublic)
+	limit, err := pagination.ParseLimit(c.Query("limit"))
+	if err == nil && c.Query("cursor") != "" {
+		var cursor pagination.Cursor
+		cursor, err = pagination.Decode(c.Query("cursor"))
+		var after time.Time
+		if err == nil {
+			after, err = time.Parse(time.RFC3339Nano, cursor.Value)
+		}
+		if err != nil || cursor.Sort != "albums" {
+			err = apperror.Field("cursor", "CURSOR_INVALID")
+		} else {
+			query = query.Where("(created_at < ?) OR (created_at = ? AND id < ?)", after, after, cursor.ID)
+		}
+	}
+	if err != nil {
+		fail(c, apperror.Invalid(err))
+		return
+	}
+
+	albums := []models.Album{}
+	if err := query.Order("created_at desc").Order("id desc").Limit(limit + 1).Find(&albums).Error; err != nil {
+		fail(c, err)
+		return
+	}
+
+	paging := pagination.Paging{Limit: limit}
+	if len(albums) > limit {
+		albums = albums[:limit]
+		last := albums[limit-1]
+		paging.HasMore = true
+		paging.NextCursor = pagination.Cursor{Sort: "albums", Value: last.CreatedAt.Format(time.RFC3339Nano), ID: last.ID}.Encode()
+	}
+
+	// Attach the owners with a single query
+	userIDs := make([]string, len(albums))
+	for i := range albums {
+		userIDs[i] = albums[i].UserID
+	}
+	owners, err := loadOwners(db, userIDs)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+	for i := range albums {
+		albums[i].Owner = owners[albums[i].UserID]
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "Success",
+		"message": translate(c, "DATA_RETRIEVED"),
+		"data":    albums,
+		"paging":  paging,
+	})
+}
+
+// GetAlbum retrieves a single album together with its ordered photos.
+func GetAlbum(c *gin.Context) {
+	// Set the database
+	db := c.MustGet("db").(*gorm.DB)
+
+	// Check if the album exists and is visible to the caller
+	var album models.Album
+	err := db.Scopes(activeUsers("user_id")).Where("id = ?", c.Param("albumId")).First(&album).Error
+	callerID := viewerID(c, db)
+	if err != nil || !album.VisibleTo(callerID) {
+		fail(c, apperror.New(apperror.AlbumNotFound).With("id", c.Param("albumId")))
+		return
+	}
+
+	err = loadAlbumOwner(db, &album)
+	if err == nil {
+		err = loadAlbumPhotos(db, &album, callerID)
+	}
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "Success",
+		"message": translate(c, "DATA_RETRIEVED"),
+		"data":    album,
+	})
+}
+
+// CreateAlbum creates a new album for the logged in user.
+func CreateAlbum(c *gin.Context) {
+	// Set the database
+	db := c.MustGet("db").(*gorm.DB)
+
+	userHasLogin, ok := currentUser(c, db)
+	if !ok {
+		return
+	}
+
+	var input app.AlbumInput
+	if !bindJSON(c, &input) {
+		return
+	}
+
+	// Initialize the album
+	inputAlbum := albumFromInput(input)
+	inputAlbum.Init()
+	inputAlbum.UserID = userHasLogin.ID
+
+	// The cover must be one of the user's own photos
+	if !ownsCoverPhoto(c, db, userHasLogin.ID, inputAlbum.CoverPhotoID) {
+		return
+	}
+
+	err := db.Create(&inputAlbum).Error
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	inputAlbum.Owner = app.Owner{
+		ID:       userHasLogin.ID,
+		Username: userHasLogin.Username,
+	}
+	inputAlbum.Photos = []models.Photo{}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "Success",
+		"message": translate(c, "ALBUM_CREATED"),
+		"data":    inputAlbum,
+	})
+}
+
+// UpdateAlbum updates an album of the logged in user.
+func UpdateAlbum(c *gin.Context) {
+	// Set the database
+	db := c.MustGet("db").(*gorm.DB)
+
+	userHasLogin, ok := currentUser(c, db)
+	if !ok {
+		return
+	}
+
+	var input app.AlbumInput
+	if !bindJSON(c, &input) {
+		return
+	}
+	albumInput := albumFromInput(input)
+	albumInput.Init()
+
+	album, ok := ownedAlbum(c, db, userHasLogin.ID, "ALBUM_FORBIDDEN")
+	if !ok {
+		return
+	}
+
+	if !ownsCoverPhoto(c, db, userHasLogin.ID, albumInput.CoverPhotoID) {
+		return
+	}
+
+	// Update the album in the database
+	err := db.Model(&album).Updates(map[string]interface{}{
+		"title":          albumInput.Title,
+		"description":    albumInput.Description,
+		"visibility":     albumInput.Visibility,
+		"cover_photo_id": albumInput.CoverPhotoID,
+	}).Error
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	album.Owner = app.Owner{
+		ID:       userHasLogin.ID,
+		Username: userHasLogin.Username,
+	}
+	if err := loadAlbumPhotos(db, &album, album.UserID); err != nil {
+		fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "Success",
+		"message": translate(c, "ALBUM_UPDATED"),
+		"data":    album,
+	})
+}
+
+// DeleteAlbum deletes an album of the logged in user. The photos themselves are kept.
+func DeleteAlbum(c *gin.Context) {
+	// Set the database
+	db := c.MustGet("db").(*gorm.DB)
+
+	userHasLogin, ok := currentUser(c, db)
+	if !ok {
+		return
+	}
+
+	album, ok := ownedAlbum(c, db, userHasLogin.ID, "ALBUM_FORBIDDEN_DELETE")
+	if !ok {
+		return
+	}
+
+	// Delete the memberships and the album
+	err := inTransaction(db, func(tx *gorm.DB) error {
+		if err := tx.Where("album_id = ?", album.ID).Delete(&models.AlbumPhoto{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&album).Error
+	})
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "Success",
+		"message": translate(c, "ALBUM_DELETED"),
+		"data":    nil,
+	})
+}
+
+// AddAlbumPhoto adds one of the user's photos to an album.
+func AddAlbumPhoto(c *gin.Context) {
+	// Set the database
+	db := c.MustGet("db").(*gorm.DB)
+
+	userHasLogin, ok := currentUser(c, db)
+	if !ok {
+		return
+	}
+
+	var input app.AlbumPhotoInput
+	if !bindJSON(c, &input) {
+		return
+	}
+
+	album, ok := ownedAlbum(c, db, userHasLogin.ID, "ALBUM_FORBIDDEN")
+	if !ok {
+		return
+	}
+
+	// Only the album owner's photos can be added
+	var photo models.Photo
+	if err := db.Where("id = ?", input.PhotoID).First(&photo).Error; err != nil {
+		fail(c, apperror.New(apperror.PhotoNotFound).With("id", input.PhotoID))
+		return
+	}
+	if photo.UserID != userHasLogin.ID {
+		fail(c, apperror.Keyed(apperror.PhotoForbidden, "PHOTO_FORBIDDEN_ADD"))
+		return
+	}
+
+	err := inTransaction(db, func(tx *gorm.DB) error {
+		members := []models.AlbumPhoto{}
+		if err := tx.Where("album_id = ?", album.ID).Order("position").Find(&members).Error; err != nil {
+			return err
+		}
+
+		// Drop an existing membership so the photo can be moved
+		ids := make([]int, 0, len(members)+1)
+		for _, m := range members {
+			if m.PhotoID != photo.ID {
+				ids = append(ids, m.PhotoID)
+			}
+		}
+
+		position := len(ids)
+		if input.Position != nil && *input.Position >= 0 && *input.Position < position {
+			position = *input.Position
+		}
+		ids = append(ids, 0)
+		copy(ids[position+1:], ids[position:])
+		ids[position] = photo.ID
+
+		return saveAlbumOrder(tx, album.ID, ids)
+	})
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	respondAlbum(c, db, album, userHasLogin, "ALBUM_PHOTO_ADDED")
+}
+
+// RemoveAlbumPhoto removes a photo from an album.
+func RemoveAlbumPhoto(c *gin.Context) {
+	// Set the database
+	db := c.MustGet("db").(*gorm.DB)
+
+	userHasLogin, ok := currentUser(c, db)
+	if !ok {
+		return
+	}
+
+	album, ok := ownedAlbum(c, db, userHasLogin.ID, "ALBUM_FORBIDDEN")
+	if !ok {
+		return
+	}
+
+	err := inTransaction(db, func(tx *gorm.DB) error {
+		members := []models.AlbumPhoto{}
+		if err := tx.Where("album_id = ?", album.ID).Order("position").Find(&members).Error; err != nil {
+			return err
+		}
+
+		ids := make([]int, 0, len(members))
+		found := false
+		for _, m := range members {
+			if c.Param("photoId") == strconv.Itoa(m.PhotoID) {
+				found = true
+				continue
+			}
+			ids = append(ids, m.PhotoID)
+		}
+		if !found {
+			return gorm.ErrRecordNotFound
+		}
+		return saveAlbumOrder(tx, album.ID, ids)
+	})
+	if gorm.IsRecordNotFoundError(err) {
+		fail(c, apperror.Keyed(apperror.PhotoNotFound, "PHOTO_NOT_IN_ALBUM").With("id", c.Param("photoId")))
+		return
+	}
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	respondAlbum(c, db, album, userHasLogin, "ALBUM_PHOTO_REMOVED")
+}
+
+// ReorderAlbumPhotos replaces the order of the photos in an album.
+func ReorderAlbumPhotos(c *gin.Context) {
+	// Set the database
+	db := c.MustGet("db").(*gorm.DB)
+
+	userHasLogin, ok := currentUser(c, db)
+	if !ok {
+		return
+	}
+
+	var input app.AlbumPhotoOrder
+	if !bindJSON(c, &input) {
+		return
+	}
+
+	album, ok := ownedAlbum(c, db, userHasLogin.ID, "ALBUM_FORBIDDEN")
+	if !ok {
+		return
+	}
+
+	err := inTransaction(db, func(tx *gorm.DB) error {
+		members := []models.AlbumPhoto{}
+		if err := tx.Where("album_id = ?", album.ID).Find(&members).Error; err != nil {
+			return err
+		}
+
+		// The new order must contain exactly the current members
+		current := make(map[int]bool, len(members))
+		for _, m := range members {
+			current[m.PhotoID] = true
+		}
+		if len(input.PhotoIDs) != len(current) {
+			return errAlbumOrder
+		}
+		for _, id := range input.PhotoIDs {
+			if !current[id] {
+				return errAlbumOrder
+			}
+			delete(current, id)
+		}
+		return saveAlbumOrder(tx, album.ID, input.PhotoIDs)
+	})
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	respondAlbum(c, db, album, userHasLogin, "ALBUM_REORDERED")
+}
+
+var errAlbumOrder = apperror.Field("photo_ids", "ALBUM_ORDER_INVALID")
+
+// albumFromInput returns the album described by a create or update request.
+func albumFromInput(input app.AlbumInput) models.Album {
+	return models.Album{
+		Title:        input.Title,
+		Description:  input.Description,
+		CoverPhotoID: input.CoverPhotoID,
+		Visibility:   input.Visibility,
+	}
+}
+
+// ownedAlbum loads the album from the URL and checks that it belongs to userID.
+// forbidden is the catalog key of the message used when it does not.
+func ownedAlbum(c *gin.Context, db *gorm.DB, userID string, forbidden string) (models.Album, bool) {
+	var album models.Album
+	if err := db.Where("id = ?", c.Param("albumId")).First(&album).Error; err != nil {
+		fail(c, apperror.New(apperror.AlbumNotFound).With("id", c.Param("albumId")))
+		return album, false
+	}
+
+	// Validate the user ID
+	if album.UserID != userID {
+		fail(c, apperror.Keyed(apperror.AlbumForbidden, forbidden))
+		return album, false
+	}
+	return album, true
+}
+
+// ownsCoverPhoto checks that the optional cover photo belongs to userID.
+func ownsCoverPhoto(c *gin.Context, db *gorm.DB, userID string, photoID *int) bool {
+	if photoID == nil {
+		return true
+	}
+
+	var photo models.Photo
+	if err := db.Where("id = ?", *photoID).First(&photo).Error; err != nil || photo.UserID != userID {
+		fail(c, apperror.Field("cover_photo_id", "ALBUM_COVER_INVALID"))
+		return false
+	}
+	return true
+}
+
+// loadAlbumOwner fills the owner of the album.
+func loadAlbumOwner(db *gorm.DB, album *models.Album) error {
+	user := models.User{}
+	if err := db.Model(&models.User{}).Where("id = ?", album.UserID).Take(&user).Error; err != nil {
+		return err
+	}
+	album.Owner = app.Owner{
+		ID:       user.ID,
+		Username: user.Username,
+	}
+	return nil
+}
+
+// loadAlbumPhotos fills the photos of the album that viewerID may see, in album order.
+// Only the album owner's photos can be members, so they share the album owner.
+func loadAlbumPhotos(db *gorm.DB, album *models.Album, viewerID string) error {
+	album.Photos = []models.Photo{}
+	err := db.Table("photos").Select("photos.*").Scopes(visiblePhotos(viewerID, false)).
+		Joins("JOIN album_photos ON album_photos.photo_id = photos.id").
+		Where("album_photos.album_id = ?", album.ID).
+		Order("album_photos.position").Find(&album.Photos).Error
+	if err != nil {
+		return err
+	}
+	for i := range album.Photos {
+		album.Photos[i].Owner = album.Owner
+	}
+	return presentPhotos(db, album.Photos, viewerID)
+}
+
+// saveAlbumOrder rewrites the memberships of an album so positions follow photoIDs.
+func saveAlbumOrder(tx *gorm.DB, albumID int, photoIDs []int) error {
+	if err := tx.Where("album_id = ?", albumID).Delete(&models.AlbumPhoto{}).Error; err != nil {
+		return err
+	}
+	for i, photoID := range photoIDs {
+		member := models.AlbumPhoto{AlbumID: albumID, PhotoID: photoID, Position: i}
+		if err := tx.Create(&member).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// respondAlbum writes the album with its current photos as a success response.
+func respondAlbum(c *gin.Context, db *gorm.DB, album models.Album, owner models.User, key string) {
+	album.Owner = app.Owner{
+		ID:       owner.ID,
+		Username: owner.Username,
+	}
+	if err := loadAlbumPhotos(db, &album, album.UserID); err != nil {
+		fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "Success",
+		"message": translate(c, key),
+		"data":    album,
+	})
+}
@@ -0,0 +1,62 @@
+package controllers_test
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"task-5-pbi-btpns-arthagusfiputra/models"
+)
+
+// TestGetAlbumsPaging checks that walking the album pages returns every public album once, newest first,
+// even when two albums share a creation time.
+func TestGetAlbumsPaging(t *testing.T) {
+	h, db := newServer(t)
+	alice, _ := seedUser(t, db, "alice")
+
+	now := time.Now().UTC().Truncate(time.Second)
+	created := []time.Time{now.Add(-3 * time.Hour), now.Add(-2 * time.Hour), now.Add(-2 * time.Hour), now.Add(-time.Hour), now}
+	// Newest first, and the later id first between equal times, is the reverse of the insert order
+	var want []int
+	for _, at := range created {
+		album := models.Album{Title: "Album", UserID: alice.ID, Visibility: models.VisibilityPublic, CreatedAt: at, UpdatedAt: at}
+		if err := db.Create(&album).Error; err != nil {
+			t.Fatal(err)
+		}
+		want = append([]int{album.ID}, want...)
+	}
+	hidden := models.Album{Title: "Hidden", UserID: alice.ID, Visibility: models.VisibilityPrivate}
+	if err := db.Create(&hidden).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	var got []int
+	cursor := ""
+	for page := 0; page < 5; page++ {
+		code, body := call(h, http.MethodGet, "/albums?limit=2&cursor="+url.QueryEscape(cursor), "", nil)
+		if code != http.StatusOK {
+			t.Fatalf("page %d: got status %d: %v", page, code, body)
+		}
+		for _, album := range body["data"].([]interface{}) {
+			got = append(got, int(album.(map[string]interface{})["id"].(float64)))
+		}
+		paging := body["paging"].(map[string]interface{})
+		if paging["has_more"] != true {
+			break
+		}
+		cursor = paging["next_cursor"].(string)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got albums %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("got albums %v, want %v", got, want)
+		}
+	}
+
+	if code, body := call(h, http.MethodGet, "/albums?cursor=bogus", "", nil); code != http.StatusUnprocessableEntity {
+		t.Errorf("bad cursor: got status %d, want %d: %v", code, http.StatusUnprocessableEntity, body)
+	}
+}
@@ -0,0 +1,259 @@
+package controllers
+
+import (
+	"net/http"
+	"time"
+
+	"task-5-pbi-btpns-arthagusfiputra/app"
+	"task-5-pbi-btpns-arthagusfiputra/app/events"
+	"task-5-pbi-btpns-arthagusfiputra/helpers/apperror"
+	"task-5-pbi-btpns-arthagusfiputra/helpers/pagination"
+	"task-5-pbi-btpns-arthagusfiputra/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+)
+
+// GetPhotoComments lists the top-level comments of a photo, oldest first, each with its replies.
+func GetPhotoComments(c *gin.Context) {
+	// Set the database
+	db := c.MustGet("db").(*gorm.DB)
+
+	photo, ok := visiblePhoto(c, db, viewerID(c, db))
+	if !ok {
+		return
+	}
+
+	query := db.Scopes(activeUsers("user_id")).Where("photo_id = ? AND parent_id IS NULL", photo.ID)
+	limit, err := pagination.ParseLimit(c.Query("limit"))
+	if err == nil && c.Query("cursor") != "" {
+		var cursor pagination.Cursor
+		cursor, err = pagination.Decode(c.Query("cursor"))
+		if err == nil && cursor.Sort != "comments" {
+			err = apperror.Field("cursor", "CURSOR_INVALID")
+		}
+		if err == nil {
+			query = query.Where("id > ?", cursor.ID)
+		}
+	}
+	if err != nil {
+		fail(c, apperror.Invalid(err))
+		return
+	}
+
+	comments := []models.Comment{}
+	err = query.Order("id asc").Limit(limit + 1).Find(&comments).Error
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	paging := pagination.Paging{Limit: limit}
+	if len(comments) > limit {
+		comments = comments[:limit]
+		paging.HasMore = true
+		paging.NextCursor = pagination.Cursor{Sort: "comments", ID: comments[limit-1].ID}.Encode()
+	}
+
+	if err := attachReplies(db, comments); err != nil {
+		fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "Success",
+		"message": translate(c, "DATA_RETRIEVED"),
+		"data":    comments,
+		"paging":  paging,
+	})
+}
+
+// CreateComment adds a comment, or a reply to a top-level comment, to a photo.
+func CreateComment(c *gin.Context) {
+	// Set the database
+	db := c.MustGet("db").(*gorm.DB)
+
+	userHasLogin, ok := currentUser(c, db)
+	if !ok {
+		return
+	}
+	photo, ok := visiblePhoto(c, db, userHasLogin.ID)
+	if !ok {
+		return
+	}
+
+	var input app.CommentInput
+	if !bindJSON(c, &input) {
+		return
+	}
+	comment := models.Comment{PhotoID: photo.ID, UserID: userHasLogin.ID, ParentID: input.ParentID, Body: input.Body}
+
+	// Initialize the comment
+	comment.Init()
+	var parent models.Comment
+	if comment.ParentID != nil {
+		// Replies only go one level deep
+		if db.Where("id = ? AND photo_id = ? AND parent_id IS NULL", *comment.ParentID, photo.ID).First(&parent).Error != nil {
+			fail(c, apperror.Field("parent_id", "COMMENT_PARENT_INVALID"))
+			return
+		}
+	}
+
+	if err := db.Create(&comment).Error; err != nil {
+		fail(c, err)
+		return
+	}
+	comment.Author = app.Owner{ID: userHasLogin.ID, Username: userHasLogin.Username}
+
+	publish(c, events.Event{Type: events.PhotoCommented, ActorID: userHasLogin.ID, UserID: photo.UserID, PhotoID: photo.ID, CommentID: comment.ID})
+	if comment.ParentID != nil {
+		publish(c, events.Event{Type: events.CommentReplied, ActorID: userHasLogin.ID, UserID: parent.UserID, PhotoID: photo.ID, CommentID: parent.ID})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "Success",
+		"message": translate(c, "COMMENT_CREATED"),
+		"data":    comment,
+	})
+}
+
+// UpdateComment changes the body of a comment. Only the author can edit it.
+func UpdateComment(c *gin.Context) {
+	// Set the database
+	db := c.MustGet("db").(*gorm.DB)
+
+	userHasLogin, ok := currentUser(c, db)
+	if !ok {
+		return
+	}
+	photo, ok := visiblePhoto(c, db, userHasLogin.ID)
+	if !ok {
+		return
+	}
+	comment, ok := photoComment(c, db, photo.ID)
+	if !ok {
+		return
+	}
+
+	// Validate the user ID
+	if comment.UserID != userHasLogin.ID {
+		fail(c, apperror.New(apperror.CommentForbidden))
+		return
+	}
+
+	var input app.CommentInput
+	if !bindJSON(c, &input) {
+		return
+	}
+	changed := models.Comment{Body: input.Body}
+	changed.Init()
+
+	// Only a different body counts as an edit
+	if changed.Body != comment.Body {
+		editedAt := time.Now()
+		err := db.Model(&comment).Updates(map[string]interface{}{"body": changed.Body, "edited_at": editedAt}).Error
+		if err != nil {
+			fail(c, err)
+			return
+		}
+	}
+	comment.Author = app.Owner{ID: userHasLogin.ID, Username: userHasLogin.Username}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "Success",
+		"message": translate(c, "COMMENT_UPDATED"),
+		"data":    comment,
+	})
+}
+
+// DeleteComment removes a comment together with its replies.
+// The author of the comment and the owner of the photo can delete it.
+func DeleteComment(c *gin.Context) {
+	// Set the database
+	db := c.MustGet("db").(*gorm.DB)
+
+	userHasLogin, ok := currentUser(c, db)
+	if !ok {
+		return
+	}
+	photo, ok := visiblePhoto(c, db, userHasLogin.ID)
+	if !ok {
+		return
+	}
+	comment, ok := photoComment(c, db, photo.ID)
+	if !ok {
+		return
+	}
+
+	// Validate the user ID
+	if comment.UserID != userHasLogin.ID && photo.UserID != userHasLogin.ID {
+		fail(c, apperror.Keyed(apperror.CommentForbidden, "COMMENT_FORBIDDEN_DELETE"))
+		return
+	}
+
+	err := inTransaction(db, func(tx *gorm.DB) error {
+		if err := tx.Where("parent_id = ?", comment.ID).Delete(&models.Comment{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&comment).Error
+	})
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "Success",
+		"message": translate(c, "COMMENT_DELETED"),
+		"data":    nil,
+	})
+}
+
+// photoComment loads the comment from the URL when it belongs to the given photo.
+func photoComment(c *gin.Context, db *gorm.DB, photoID int) (models.Comment, bool) {
+	var comment models.Comment
+	if err := db.Where("id = ? AND photo_id = ?", c.Param("commentId"), photoID).First(&comment).Error; err != nil {
+		fail(c, apperror.New(apperror.CommentNotFound).With("id", c.Param("commentId")))
+		return comment, false
+	}
+	return comment, true
+}
+
+// attachReplies loads the replies of the given top-level comments and the authors of all of them.
+func attachReplies(db *gorm.DB, comments []models.Comment) error {
+	if len(comments) == 0 {
+		return nil
+	}
+
+	ids := make([]int, len(comments))
+	for i := range comments {
+		ids[i] = comments[i].ID
+	}
+	replies := []models.Comment{}
+	if err := db.Scopes(activeUsers("user_id")).Where("parent_id IN (?)", ids).Order("id asc").Find(&replies).Error; err != nil {
+		return err
+	}
+
+	userIDs := make([]string, 0, len(comments)+len(replies))
+	for _, comment := range comments {
+		userIDs = append(userIDs, comment.UserID)
+	}
+	for _, reply := range replies {
+		userIDs = append(userIDs, reply.UserID)
+	}
+	owners, err := loadOwners(db, userIDs)
+	if err != nil {
+		return err
+	}
+
+	byParent := make(map[int][]models.Comment, len(comments))
+	for _, reply := range replies {
+		reply.Author = owners[reply.UserID]
+		byParent[*reply.ParentID] = append(byParent[*reply.ParentID], reply)
+	}
+	for i := range comments {
+		comments[i].Author = owners[comments[i].UserID]
+		comments[i].Replies = byParent[comments[i].ID]
+	}
+	return nil
+}
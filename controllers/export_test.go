@@ -0,0 +1,10 @@
+package controllers
+
+import "task-5-pbi-btpns-arthagusfiputra/app/remote"
+
+// UsePhotoFetcher replaces the fetcher of photos submitted by URL until the returned function is called.
+func UsePhotoFetcher(f *remote.Fetcher) func() {
+	previous := photoFetcher
+	photoFetcher = f
+	return func() { photoFetcher = previous }
+}
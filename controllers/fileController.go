@@ -0,0 +1,46 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"task-5-pbi-btpns-arthagusfiputra/app/storage"
+	"task-5-pbi-btpns-arthagusfiputra/helpers/apperror"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ServeFile streams a stored photo file for a signed, unexpired URL.
+// Range requests and conditional requests are handled by http.ServeContent.
+func ServeFile(c *gin.Context) {
+	// Set the storage
+	store := c.MustGet("storage").(storage.Storage)
+
+	key := strings.TrimPrefix(c.Param("key"), "/")
+	expiresAt, err := storage.Verify(key, c.Query("expires"), c.Query("signature"))
+	if err != nil {
+		fail(c, apperror.New(apperror.FileForbidden).Because(err))
+		return
+	}
+
+	object, err := store.Open(key)
+	if err != nil {
+		fail(c, apperror.New(apperror.FileNotFound))
+		return
+	}
+	defer object.Close()
+
+	// Browsers may cache the file for as long as the URL stays valid
+	maxAge := int(time.Until(expiresAt).Seconds())
+	header := c.Writer.Header()
+	header.Set("Cache-Control", "private, max-age="+strconv.Itoa(maxAge))
+	header.Set("ETag", `"`+strconv.FormatInt(object.Size, 36)+"-"+strconv.FormatInt(object.ModTime.UnixNano(), 36)+`"`)
+	header.Set("X-Content-Type-Options", "nosniff")
+	if object.ContentType != "" {
+		header.Set("Content-Type", object.ContentType)
+	}
+
+	http.ServeContent(c.Writer, c.Request, key, object.ModTime, object)
+}
@@ -0,0 +1,63 @@
+package controllers_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"task-5-pbi-btpns-arthagusfiputra/app/storage"
+)
+
+// TestServeFile checks that a stored file is served through a signed URL, in ranges, and not past its expiry.
+func TestServeFile(t *testing.T) {
+	h, _ := newServer(t)
+	const content = "0123456789"
+	if _, err := storage.Default().Put("tests/file.txt", strings.NewReader(content)); err != nil {
+		t.Fatal(err)
+	}
+	get := func(target string, header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		for name, values := range header {
+			req.Header[name] = values
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w
+	}
+	signed := storage.SignURL("tests/file.txt", time.Minute)
+
+	w := get(signed, nil)
+	if w.Code != http.StatusOK || w.Body.String() != content {
+		t.Errorf("whole file: got status %d with %q", w.Code, w.Body.String())
+	}
+	if etag := w.Header().Get("ETag"); etag == "" {
+		t.Error("whole file: no ETag")
+	} else if w := get(signed, http.Header{"If-None-Match": {etag}}); w.Code != http.StatusNotModified {
+		t.Errorf("matching ETag: got status %d, want %d", w.Code, http.StatusNotModified)
+	}
+
+	w = get(signed, http.Header{"Range": {"bytes=2-5"}})
+	body, _ := io.ReadAll(w.Body)
+	if w.Code != http.StatusPartialContent || string(body) != "2345" || w.Header().Get("Content-Range") != "bytes 2-5/10" {
+		t.Errorf("range: got status %d with %q and Content-Range %q", w.Code, body, w.Header().Get("Content-Range"))
+	}
+	if w := get(signed, http.Header{"Range": {"bytes=20-30"}}); w.Code != http.StatusRequestedRangeNotSatisfiable {
+		t.Errorf("range past the end: got status %d, want %d", w.Code, http.StatusRequestedRangeNotSatisfiable)
+	}
+
+	parsed, _ := url.Parse(signed)
+	query := parsed.Query()
+	query.Set("signature", strings.Repeat("0", len(query.Get("signature"))))
+	forged := "/files/tests/file.txt?" + query.Encode()
+	expired := storage.SignURL("tests/file.txt", -2*time.Minute)
+	other := strings.Replace(signed, "file.txt", "other.txt", 1)
+	for name, target := range map[string]string{"forged": forged, "expired": expired, "other key": other, "unsigned": "/files/tests/file.txt"} {
+		if w := get(target, nil); w.Code != http.StatusForbidden {
+			t.Errorf("%s: got status %d, want %d", name, w.Code, http.StatusForbidden)
+		}
+	}
+}
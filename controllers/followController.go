@@ -0,0 +1,245 @@
+package controllers
+
+import (
+	"net/http"
+
+	"task-5-pbi-btpns-arthagusfiputra/app"
+	"task-5-pbi-btpns-arthagusfiputra/app/events"
+	"task-5-pbi-btpns-arthagusfiputra/helpers/apperror"
+	"task-5-pbi-btpns-arthagusfiputra/helpers/pagination"
+	"task-5-pbi-btpns-arthagusfiputra/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+)
+
+// FollowUser makes the logged in user follow another user. Following twice has no further effect.
+func FollowUser(c *gin.Context) {
+	// Set the database
+	db := c.MustGet("db").(*gorm.DB)
+
+	userHasLogin, ok := currentUser(c, db)
+	if !ok {
+		return
+	}
+	user, ok := followableUser(c, db, userHasLogin.ID)
+	if !ok {
+		return
+	}
+
+	follow := models.Follow{FollowerID: userHasLogin.ID, FolloweeID: user.ID}
+	if err := db.Create(&follow).Error; err != nil {
+		// The unique index rejects a second follow, which is not an error for the caller
+		if db.Where("follower_id = ? AND followee_id = ?", userHasLogin.ID, user.ID).First(&models.Follow{}).Error != nil {
+			fail(c, err)
+			return
+		}
+	} else {
+		publish(c, events.Event{Type: events.UserFollowed, ActorID: userHasLogin.ID, UserID: user.ID})
+	}
+
+	respondFollow(c, db, user.ID, true, "USER_FOLLOWED")
+}
+
+// UnfollowUser makes the logged in user stop following another user. Unfollowing twice has no further effect.
+func UnfollowUser(c *gin.Context) {
+	// Set the database
+	db := c.MustGet("db").(*gorm.DB)
+
+	userHasLogin, ok := currentUser(c, db)
+	if !ok {
+		return
+	}
+	user, ok := followableUser(c, db, userHasLogin.ID)
+	if !ok {
+		return
+	}
+
+	if err := db.Where("follower_id = ? AND followee_id = ?", userHasLogin.ID, user.ID).Delete(&models.Follow{}).Error; err != nil {
+		fail(c, err)
+		return
+	}
+
+	respondFollow(c, db, user.ID, false, "USER_UNFOLLOWED")
+}
+
+// GetFollowers lists the users following a user, most recent first.
+func GetFollowers(c *gin.Context) {
+	listFollows(c, "followee_id", "follower_id")
+}
+
+// GetFollowing lists the users a user follows, most recent first.
+func GetFollowing(c *gin.Context) {
+	listFollows(c, "follower_id", "followee_id")
+}
+
+// GetFeed retrieves the photos of the users followed by the logged in user, newest first.
+func GetFeed(c *gin.Context) {
+	// Set the database
+	db := c.MustGet("db").(*gorm.DB)
+
+	userHasLogin, ok := currentUser(c, db)
+	if !ok {
+		return
+	}
+
+	// The feed always runs newest first so its cursor stays cheap to follow
+	query, err := parsePhotoQuery(c)
+	if err == nil && (query.Sort != "created_at" || !query.Desc) {
+		err = apperror.Field("sort", "FEED_SORT_INVALID")
+	}
+	if err != nil {
+		fail(c, apperror.Invalid(err))
+		return
+	}
+
+	// Following a few users, their photos are joined through idx_photos_user_created.
+	// Following many, joining them all would cost more than walking the newest photos and keeping those of followed users.
+	photos := []models.Photo{}
+	scoped := db.Table("photos").Select("photos.*").
+		Where("photos.deleted_at IS NULL AND photos.visibility IN (?)", []string{models.VisibilityPublic, models.VisibilityFollowers})
+	many, err := followsMany(db, userHasLogin.ID)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+	if many {
+		scoped = scoped.Where("EXISTS (SELECT 1 FROM follows WHERE follows.follower_id = ? AND follows.followee_id = photos.user_id)", userHasLogin.ID)
+	} else {
+		scoped = scoped.Joins("JOIN follows ON follows.followee_id = photos.user_id AND follows.follower_id = ?", userHasLogin.ID)
+	}
+	if err := query.apply(scoped).Find(&photos).Error; err != nil {
+		fail(c, err)
+		return
+	}
+	paging := query.page(&photos)
+
+	// Attach the owners with a single query
+	err = attachOwners(db, photos)
+	if err == nil {
+		err = presentPhotos(db, photos, userHasLogin.ID)
+	}
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "Success",
+		"message": translate(c, "DATA_RETRIEVED"),
+		"data":    photos,
+		"paging":  paging,
+	})
+}
+
+// feedJoinLimit is the number of followees up to which the feed joins their photos.
+const feedJoinLimit = 1000
+
+// followsMany reports whether the user follows more than feedJoinLimit users, without counting them all.
+func followsMany(db *gorm.DB, userID string) (bool, error) {
+	ids := []int{}
+	err := db.Model(&models.Follow{}).Where("follower_id = ?", userID).Offset(feedJoinLimit).Limit(1).Pluck("id", &ids).Error
+	return len(ids) > 0, err
+}
+
+// followableUser loads the user from the URL and makes sure it isn't the caller.
+func followableUser(c *gin.Context, db *gorm.DB, userID string) (models.User, bool) {
+	var user models.User
+	if err := db.Where("id = ?", c.Param("userId")).First(&user).Error; err != nil {
+		fail(c, apperror.New(apperror.UserNotFound).With("id", c.Param("userId")))
+		return user, false
+	}
+
+	if user.ID == userID {
+		fail(c, apperror.Keyed(apperror.ValidationFailed, "FOLLOW_SELF"))
+		return user, false
+	}
+	return user, true
+}
+
+// respondFollow writes the follow state and the follower count of a user as a success response.
+func respondFollow(c *gin.Context, db *gorm.DB, userID string, following bool, key string) {
+	var count int
+	if err := db.Model(&models.Follow{}).Scopes(activeUsers("follower_id")).Where("followee_id = ?", userID).Count(&count).Error; err != nil {
+		fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "Success",
+		"message": translate(c, key),
+		"data": gin.H{
+			"user_id":        userID,
+			"follower_count": count,
+			"following":      following,
+		},
+	})
+}
+
+// listFollows lists one side of the follows of the user in the URL.
+// column holds the user from the URL and other the users listed.
+func listFollows(c *gin.Context, column string, other string) {
+	// Set the database
+	db := c.MustGet("db").(*gorm.DB)
+
+	// Check if the user exists
+	var user models.User
+	if err := db.Where("id = ?", c.Param("userId")).First(&user).Error; err != nil {
+		fail(c, apperror.New(apperror.UserNotFound).With("id", c.Param("userId")))
+		return
+	}
+
+	query := db.Table("follows").Scopes(activeUsers("follows."+other)).Where("follows."+column+" = ?", user.ID)
+	var total int
+	err := query.Count(&total).Error
+
+	limit, perr := pagination.ParseLimit(c.Query("limit"))
+	if perr == nil && c.Query("cursor") != "" {
+		var cursor pagination.Cursor
+		cursor, perr = pagination.Decode(c.Query("cursor"))
+		if perr == nil && cursor.Sort != "follows" {
+			perr = apperror.Field("cursor", "CURSOR_INVALID")
+		}
+		if perr == nil {
+			query = query.Where("follows.id < ?", cursor.ID)
+		}
+	}
+	if perr != nil {
+		fail(c, apperror.Invalid(perr))
+		return
+	}
+
+	type followRow struct {
+		FollowID int
+		app.FollowUser
+	}
+	rows := []followRow{}
+	if err == nil {
+		err = query.Select("follows.id AS follow_id, users.id AS id, users.username AS username, follows.created_at AS followed_at").
+			Joins("JOIN users ON users.id = follows." + other).
+			Order("follows.id desc").Limit(limit + 1).Scan(&rows).Error
+	}
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	paging := pagination.Paging{Limit: limit}
+	if len(rows) > limit {
+		rows = rows[:limit]
+		paging.HasMore = true
+		paging.NextCursor = pagination.Cursor{Sort: "follows", ID: rows[limit-1].FollowID}.Encode()
+	}
+	users := make([]app.FollowUser, len(rows))
+	for i, row := range rows {
+		users[i] = row.FollowUser
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "Success",
+		"message": translate(c, "DATA_RETRIEVED"),
+		"data":    users,
+		"count":   total,
+		"paging":  paging,
+	})
+}
@@ -0,0 +1,112 @@
+package controllers_test
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"task-5-pbi-btpns-arthagusfiputra/controllers"
+
+	"github.com/jinzhu/gorm"
+)
+
+// seedFollowees makes follower follow n new users with a photo each.
+// The rows are generated by SQLite itself, as inserting tens of thousands through gorm takes minutes.
+func seedFollowees(tb testing.TB, db *gorm.DB, followerID string, n int) {
+	tb.Helper()
+	statements := []string{
+		`INSERT INTO users (id, username, email, password, version, created_at, updated_at)
+			SELECT 'followee' || i, 'followee' || i, 'followee' || i || '@example.com', '-', 1, datetime('now', '-' || i || ' minutes'), datetime('now')
+			FROM seq`,
+		`INSERT INTO photos (title, caption, photo_url, user_id, visibility, version, like_count, created_at, updated_at)
+			SELECT 'Photo ' || i, 'caption', 'https://example.com/' || i || '.jpg', 'followee' || i, 'public', 1, 0, datetime('now', '-' || i || ' minutes'), datetime('now')
+			FROM seq`,
+		`INSERT INTO follows (follower_id, followee_id, created_at)
+			SELECT ?, 'followee' || i, datetime('now') FROM seq`,
+	}
+	for _, statement := range statements {
+		sql := "WITH RECURSIVE seq(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM seq WHERE i < ?) " + statement
+		args := []interface{}{n}
+		if strings.Contains(statement, "?") {
+			args = append(args, followerID)
+		}
+		if err := db.Exec(sql, args...).Error; err != nil {
+			tb.Fatal(err)
+		}
+	}
+	if err := db.Exec("ANALYZE").Error; err != nil {
+		tb.Fatal(err)
+	}
+}
+
+// feedPlan runs a feed request and returns the SQLite query plan of its photo query.
+func feedPlan(tb testing.TB, db *gorm.DB, h http.Handler, token string) string {
+	tb.Helper()
+	var sql string
+	var vars []interface{}
+	db.Callback().Query().After("gorm:query").Register("test:capture_feed", func(scope *gorm.Scope) {
+		if scope.TableName() == "photos" && strings.Contains(scope.SQL, "follows") {
+			sql, vars = scope.SQL, scope.SQLVars
+		}
+	})
+	defer db.Callback().Query().Remove("test:capture_feed")
+
+	if code, body := call(h, http.MethodGet, "/feed", token, nil); code != http.StatusOK {
+		tb.Fatalf("got status %d: %v", code, body)
+	}
+	if sql == "" {
+		tb.Fatal("the feed query was not captured")
+	}
+
+	rows, err := db.Raw("EXPLAIN QUERY PLAN "+sql, vars...).Rows()
+	if err != nil {
+		tb.Fatal(err)
+	}
+	defer rows.Close()
+
+	var plan []string
+	for rows.Next() {
+		var id, parent, unused int
+		var detail string
+		if err := rows.Scan(&id, &parent, &unused, &detail); err != nil {
+			tb.Fatal(err)
+		}
+		plan = append(plan, detail)
+	}
+	return strings.Join(plan, "\n")
+}
+
+// BenchmarkGetFeed measures the first page of the feed of a user following up to 20,000 users.
+// Up to 1,000 followees their photos are joined through idx_photos_user_created; past that the feed walks
+// idx_photos_created_at and checks each photo against idx_follows_pair. Either way the time per page
+// should stay flat as the number of followees grows.
+func BenchmarkGetFeed(b *testing.B) {
+	cases := []struct {
+		followees int
+		index     string
+	}{
+		{100, "idx_photos_user_created"},
+		{1000, "idx_photos_user_created"},
+		{5000, "idx_photos_created_at"},
+		{20000, "idx_photos_created_at"},
+	}
+	for _, tc := range cases {
+		db := openDB(b)
+		follower, token := seedUser(b, db, "follower")
+		seedFollowees(b, db, follower.ID, tc.followees)
+		h := newHandler(db, http.MethodGet, "/feed", controllers.GetFeed)
+
+		if plan := feedPlan(b, db, h, token); !strings.Contains(plan, tc.index) {
+			b.Fatalf("%d followees: the feed query doesn't use %s:\n%s", tc.followees, tc.index, plan)
+		}
+
+		b.Run(fmt.Sprintf("followees=%d", tc.followees), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if code, body := call(h, http.MethodGet, "/feed", token, nil); code != http.StatusOK {
+					b.Fatalf("got status %d: %v", code, body)
+				}
+			}
+		})
+	}
+}
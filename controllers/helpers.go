@@ -0,0 +1,245 @@
+package controllers
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"strings"
+
+	"task-5-pbi-btpns-arthagusfiputra/app"
+	"task-5-pbi-btpns-arthagusfiputra/app/auth"
+	"task-5-pbi-btpns-arthagusfiputra/app/events"
+	"task-5-pbi-btpns-arthagusfiputra/helpers/apperror"
+	"task-5-pbi-btpns-arthagusfiputra/helpers/i18n"
+	"task-5-pbi-btpns-arthagusfiputra/helpers/mergepatch"
+	"task-5-pbi-btpns-arthagusfiputra/helpers/validation"
+	"task-5-pbi-btpns-arthagusfiputra/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+)
+
+// bearerToken returns the token part of the Authorization header.
+func bearerToken(c *gin.Context) string {
+	tokenString := c.GetHeader("Authorization")
+	if !strings.HasPrefix(tokenString, "Bearer ") {
+		return ""
+	}
+	return strings.TrimPrefix(tokenString, "Bearer ")
+}
+
+// fail hands err to the error middleware, which writes the response, and stops the handler chain.
+func fail(c *gin.Context, err error) {
+	_ = c.Error(err)
+	c.Abort()
+}
+
+// bindJSON decodes the JSON request body into the DTO input and checks its validation rules.
+// It reports the error itself and returns false when the body is malformed or invalid.
+func bindJSON(c *gin.Context, input interface{}) bool {
+	// Read the request body
+	body, err := ioutil.ReadAll(c.Request.Body)
+	if err == nil {
+		// Convert JSON to an object
+		err = json.Unmarshal(body, input)
+	}
+	if err != nil {
+		fail(c, apperror.Malformed(err))
+		return false
+	}
+
+	if err := validation.Struct(input); err != nil {
+		fail(c, apperror.Invalid(err))
+		return false
+	}
+	return true
+}
+
+// bindMergePatch applies the JSON Merge Patch in the request body to current and decodes the result into the DTO input.
+// It returns the top-level members the patch names, and reports the error itself when it returns false.
+func bindMergePatch(c *gin.Context, current interface{}, input interface{}) (map[string]bool, bool) {
+	if contentType := c.ContentType(); contentType != mergepatch.ContentType && contentType != "application/json" {
+		fail(c, apperror.New(apperror.UnsupportedMedia))
+		return nil, false
+	}
+
+	// Read the request body
+	patch, err := ioutil.ReadAll(c.Request.Body)
+	var keys map[string]bool
+	if err == nil {
+		keys, err = mergepatch.Keys(patch)
+	}
+	var doc, merged []byte
+	if err == nil {
+		doc, err = json.Marshal(current)
+	}
+	if err == nil {
+		merged, err = mergepatch.Apply(doc, patch)
+	}
+	if err == nil {
+		err = json.Unmarshal(merged, input)
+	}
+	if err != nil {
+		fail(c, apperror.Malformed(err))
+		return nil, false
+	}
+
+	if err := validation.Struct(input); err != nil {
+		fail(c, apperror.Invalid(err))
+		return nil, false
+	}
+	return keys, true
+}
+
+// currentUser loads the user that owns the bearer token.
+// It reports the error itself and returns false when the user can't be resolved.
+func currentUser(c *gin.Context, db *gorm.DB) (models.User, bool) {
+	var user models.User
+
+	tokenString := bearerToken(c)
+	if tokenString == "" {
+		fail(c, apperror.Keyed(apperror.Unauthorized, "TOKEN_MISSING"))
+		return user, false
+	}
+
+	// Get the user email from JWT
+	email, err := auth.GetEmail(tokenString)
+	if err != nil {
+		fail(c, apperror.Keyed(apperror.Unauthorized, "TOKEN_INVALID").Because(err))
+		return user, false
+	}
+
+	// Get user data from the database
+	if err := db.Where("email = ?", email).First(&user).Error; err != nil {
+		fail(c, apperror.Keyed(apperror.Unauthorized, "USER_EMAIL_NOT_FOUND").With("email", email))
+		return user, false
+	}
+
+	preferLocale(c, user.Locale)
+	return user, true
+}
+
+// preferLocale answers in the locale a user chose, which wins over the Accept-Language header.
+func preferLocale(c *gin.Context, preferred string) {
+	if locale, ok := i18n.Match(preferred); ok {
+		c.Set("locale", locale)
+		c.Header("Content-Language", locale)
+	}
+}
+
+// translate returns the catalog message under key in the locale of the response.
+func translate(c *gin.Context, key string) string {
+	return i18n.T(c.GetString("locale"), key, nil)
+}
+
+// currentAdmin returns the logged in user when they are an administrator.
+// It reports the error itself and returns false otherwise.
+func currentAdmin(c *gin.Context, db *gorm.DB) (models.User, bool) {
+	user, ok := currentUser(c, db)
+	if ok && !user.Admin {
+		fail(c, apperror.Keyed(apperror.Forbidden, "ADMIN_REQUIRED"))
+		return user, false
+	}
+	return user, ok
+}
+
+// viewer returns the user identified by OptionalAuthMiddleware, or nil for anonymous requests.
+// The user is loaded once per request.
+func viewer(c *gin.Context, db *gorm.DB) *models.User {
+	if cached, ok := c.Get("viewer"); ok {
+		return cached.(*models.User)
+	}
+
+	var found *models.User
+	if email := c.GetString("email"); email != "" {
+		var user models.User
+		if err := db.Where("email = ?", email).First(&user).Error; err == nil {
+			found = &user
+			preferLocale(c, user.Locale)
+		}
+	}
+	c.Set("viewer", found)
+	return found
+}
+
+// viewerID returns the ID of the calling user, or an empty string for anonymous requests.
+func viewerID(c *gin.Context, db *gorm.DB) string {
+	if user := viewer(c, db); user != nil {
+		return user.ID
+	}
+	return ""
+}
+
+// activeUsers leaves out the rows that belong to deleted users through the given user ID column.
+func activeUsers(column string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		deleted := db.New().Unscoped().Model(&models.User{}).Select("id").Where("deleted_at IS NOT NULL").SubQuery()
+		return db.Where(column+" NOT IN ?", deleted)
+	}
+}
+
+// loadOwners fetches the owners for the given user IDs in one query, keyed by user ID.
+// IDs without a matching user are simply absent from the map.
+func loadOwners(db *gorm.DB, userIDs []string) (map[string]app.Owner, error) {
+	owners := make(map[string]app.Owner, len(userIDs))
+	if len(userIDs) == 0 {
+		return owners, nil
+	}
+
+	// Deduplicate the IDs before querying
+	seen := make(map[string]bool, len(userIDs))
+	ids := make([]string, 0, len(userIDs))
+	for _, id := range userIDs {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+
+	users := []app.Owner{}
+	if err := db.Table("users").Select("id, username").Where("id IN (?)", ids).Scan(&users).Error; err != nil {
+		return nil, err
+	}
+	for _, user := range users {
+		owners[user.ID] = user
+	}
+	return owners, nil
+}
+
+// attachOwners fills the Owner of every photo using a single batched query.
+// Photos whose owner no longer exists keep an empty Owner instead of failing the list.
+func attachOwners(db *gorm.DB, photos []models.Photo) error {
+	userIDs := make([]string, len(photos))
+	for i := range photos {
+		userIDs[i] = photos[i].UserID
+	}
+
+	owners, err := loadOwners(db, userIDs)
+	if err != nil {
+		return err
+	}
+	for i := range photos {
+		photos[i].Owner = owners[photos[i].UserID]
+	}
+	return nil
+}
+
+// presentPhotos fills the tags, signed file URLs and viewer's likes returned with every photo.
+func presentPhotos(db *gorm.DB, photos []models.Photo, viewerID string) error {
+	if err := attachTags(db, photos); err != nil {
+		return err
+	}
+	if err := attachLikedByMe(db, photos, viewerID); err != nil {
+		return err
+	}
+	for i := range photos {
+		photos[i].SignURL()
+	}
+	return nil
+}
+
+// publish hands an event to the bus set up by the router.
+func publish(c *gin.Context, e events.Event) {
+	if bus, ok := c.Get("events"); ok {
+		bus.(*events.Bus).Publish(e)
+	}
+}
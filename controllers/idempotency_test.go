@@ -0,0 +1,96 @@
+package controllers_test
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"task-5-pbi-btpns-arthagusfiputra/models"
+
+	"github.com/jinzhu/gorm"
+)
+
+// TestIdempotentCreatePhoto checks that a retry gets the first response, that the key can't be reused
+// for another request and that a request failing with a server error releases its key.
+func TestIdempotentCreatePhoto(t *testing.T) {
+	h, db := newServer(t)
+	alice, aliceToken := seedUser(t, db, "alice")
+	_, bobToken := seedUser(t, db, "bob")
+	key := http.Header{"Idempotency-Key": {"photo-1"}}
+	photo := map[string]string{"title": "Sea", "caption": "caption", "photo_url": "https://example.com/sea.jpg"}
+	photos := func() int {
+		t.Helper()
+		var count int
+		if err := db.Model(&models.Photo{}).Where("user_id = ?", alice.ID).Count(&count).Error; err != nil {
+			t.Fatal(err)
+		}
+		return count
+	}
+
+	// The first write fails with a server error, which must not be replayed
+	db.Callback().Create().Before("gorm:create").Register("test:fail", func(scope *gorm.Scope) {
+		if scope.TableName() == "photos" {
+			scope.Err(errors.New("disk full"))
+		}
+	})
+	code, header, body := callWithHeader(h, http.MethodPost, "/photos", aliceToken, key, photo)
+	db.Callback().Create().Remove("test:fail")
+	if code != http.StatusInternalServerError {
+		t.Fatalf("failing write: got status %d: %v", code, body)
+	}
+
+	code, header, body = callWithHeader(h, http.MethodPost, "/photos", aliceToken, key, photo)
+	if code != http.StatusOK || header.Get("Idempotent-Replayed") != "" {
+		t.Fatalf("retry after the server error: got status %d, replayed %q: %v", code, header.Get("Idempotent-Replayed"), body)
+	}
+	id := body["data"].(map[string]interface{})["id"]
+
+	code, header, body = callWithHeader(h, http.MethodPost, "/photos", aliceToken, key, photo)
+	if code != http.StatusOK || header.Get("Idempotent-Replayed") != "true" || body["data"].(map[string]interface{})["id"] != id {
+		t.Errorf("retry: got status %d, replayed %q: %v, want photo %v replayed", code, header.Get("Idempotent-Replayed"), body, id)
+	}
+
+	other := map[string]string{"title": "Sky", "caption": "caption", "photo_url": "https://example.com/sky.jpg"}
+	if code, _, body := callWithHeader(h, http.MethodPost, "/photos", aliceToken, key, other); code != http.StatusUnprocessableEntity {
+		t.Errorf("key reused for another photo: got status %d: %v", code, body)
+	}
+	if count := photos(); count != 1 {
+		t.Errorf("got %d photos, want 1", count)
+	}
+
+	// Keys belong to their user
+	if code, header, body := callWithHeader(h, http.MethodPost, "/photos", bobToken, key, photo); code != http.StatusOK || header.Get("Idempotent-Replayed") != "" {
+		t.Errorf("same key from another user: got status %d, replayed %q: %v", code, header.Get("Idempotent-Replayed"), body)
+	}
+}
+
+// TestIdempotentRegister checks that anonymous keys are replayed to a retry without being shared between clients.
+func TestIdempotentRegister(t *testing.T) {
+	h, db := newServer(t)
+	key := http.Header{"Idempotency-Key": {"register"}}
+	carol := map[string]string{"username": "carol", "email": "carol@example.com", "password": "Secret-123"}
+	dave := map[string]string{"username": "dave", "email": "dave@example.com", "password": "Secret-456"}
+
+	first, _, body := callWithHeader(h, http.MethodPost, "/users/register", "", key, carol)
+	if first >= http.StatusBadRequest {
+		t.Fatalf("register: got status %d: %v", first, body)
+	}
+	code, header, body := callWithHeader(h, http.MethodPost, "/users/register", "", key, carol)
+	if code != first || header.Get("Idempotent-Replayed") != "true" {
+		t.Errorf("retry: got status %d, replayed %q: %v", code, header.Get("Idempotent-Replayed"), body)
+	}
+
+	// Another client happening to pick the same key registers as usual
+	code, header, body = callWithHeader(h, http.MethodPost, "/users/register", "", key, dave)
+	if code != first || header.Get("Idempotent-Replayed") != "" {
+		t.Errorf("same key from another client: got status %d, replayed %q: %v", code, header.Get("Idempotent-Replayed"), body)
+	}
+
+	var users int
+	if err := db.Model(&models.User{}).Where("email IN (?)", []string{carol["email"], dave["email"]}).Count(&users).Error; err != nil {
+		t.Fatal(err)
+	}
+	if users != 2 {
+		t.Errorf("got %d users, want 2", users)
+	}
+}
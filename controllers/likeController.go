@@ -0,0 +1,206 @@
+package controllers
+
+import (
+	"net/http"
+
+	"task-5-pbi-btpns-arthagusfiputra/app"
+	"task-5-pbi-btpns-arthagusfiputra/app/events"
+	"task-5-pbi-btpns-arthagusfiputra/helpers/apperror"
+	"task-5-pbi-btpns-arthagusfiputra/helpers/pagination"
+	"task-5-pbi-btpns-arthagusfiputra/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+)
+
+// LikePhoto likes a photo for the logged in user. Liking twice has no further effect.
+func LikePhoto(c *gin.Context) {
+	// Set the database
+	db := c.MustGet("db").(*gorm.DB)
+
+	userHasLogin, ok := currentUser(c, db)
+	if !ok {
+		return
+	}
+	photo, ok := visiblePhoto(c, db, userHasLogin.ID)
+	if !ok {
+		return
+	}
+
+	liked := false
+	err := inTransaction(db, func(tx *gorm.DB) error {
+		like := models.Like{PhotoID: photo.ID, UserID: userHasLogin.ID}
+		if err := tx.Create(&like).Error; err != nil {
+			// The unique index rejects a second like, which is not an error for the caller
+			if tx.Where("photo_id = ? AND user_id = ?", photo.ID, userHasLogin.ID).First(&models.Like{}).Error == nil {
+				return nil
+			}
+			return err
+		}
+		liked = true
+		return tx.Model(&models.Photo{}).Where("id = ?", photo.ID).
+			UpdateColumn("like_count", gorm.Expr("like_count + 1")).Error
+	})
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	if liked {
+		publish(c, events.Event{Type: events.PhotoLiked, ActorID: userHasLogin.ID, UserID: photo.UserID, PhotoID: photo.ID})
+	}
+
+	respondLikes(c, db, photo.ID, true, "PHOTO_LIKED")
+}
+
+// UnlikePhoto removes the like of the logged in user. Unliking twice has no further effect.
+func UnlikePhoto(c *gin.Context) {
+	// Set the database
+	db := c.MustGet("db").(*gorm.DB)
+
+	userHasLogin, ok := currentUser(c, db)
+	if !ok {
+		return
+	}
+	photo, ok := visiblePhoto(c, db, userHasLogin.ID)
+	if !ok {
+		return
+	}
+
+	err := inTransaction(db, func(tx *gorm.DB) error {
+		result := tx.Where("photo_id = ? AND user_id = ?", photo.ID, userHasLogin.ID).Delete(&models.Like{})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		return tx.Model(&models.Photo{}).Where("id = ?", photo.ID).
+			UpdateColumn("like_count", gorm.Expr("like_count - ?", result.RowsAffected)).Error
+	})
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	respondLikes(c, db, photo.ID, false, "PHOTO_UNLIKED")
+}
+
+// GetPhotoLikes lists the users who liked a photo, newest first.
+func GetPhotoLikes(c *gin.Context) {
+	// Set the database
+	db := c.MustGet("db").(*gorm.DB)
+
+	photo, ok := visiblePhoto(c, db, viewerID(c, db))
+	if !ok {
+		return
+	}
+
+	limit, err := pagination.ParseLimit(c.Query("limit"))
+	if err == nil && c.Query("cursor") != "" {
+		var cursor pagination.Cursor
+		cursor, err = pagination.Decode(c.Query("cursor"))
+		if err == nil && cursor.Sort != "likes" {
+			err = apperror.Field("cursor", "CURSOR_INVALID")
+		}
+		if err == nil {
+			db = db.Where("likes.id < ?", cursor.ID)
+		}
+	}
+	if err != nil {
+		fail(c, apperror.Invalid(err))
+		return
+	}
+
+	type likeRow struct {
+		LikeID int
+		app.Liker
+	}
+	rows := []likeRow{}
+	err = db.Table("likes").Select("likes.id AS like_id, users.id AS id, users.username AS username, likes.created_at AS liked_at").
+		Joins("JOIN users ON users.id = likes.user_id").
+		Where("likes.photo_id = ? AND users.deleted_at IS NULL", photo.ID).Order("likes.id desc").Limit(limit + 1).Scan(&rows).Error
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	paging := pagination.Paging{Limit: limit}
+	if len(rows) > limit {
+		rows = rows[:limit]
+		paging.HasMore = true
+		paging.NextCursor = pagination.Cursor{Sort: "likes", ID: rows[limit-1].LikeID}.Encode()
+	}
+	likers := make([]app.Liker, len(rows))
+	for i, row := range rows {
+		likers[i] = row.Liker
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "Success",
+		"message": translate(c, "DATA_RETRIEVED"),
+		"data":    likers,
+		"paging":  paging,
+	})
+}
+
+// visiblePhoto loads the photo from the URL when userID may see it.
+func visiblePhoto(c *gin.Context, db *gorm.DB, userID string) (models.Photo, bool) {
+	var photo models.Photo
+	err := db.Scopes(visiblePhotos(userID, true)).Where("photos.id = ?", c.Param("photoId")).First(&photo).Error
+	if err != nil {
+		fail(c, apperror.New(apperror.PhotoNotFound).With("id", c.Param("photoId")))
+		return photo, false
+	}
+	return photo, true
+}
+
+// respondLikes writes the current like count of a photo as a success response.
+func respondLikes(c *gin.Context, db *gorm.DB, photoID int, liked bool, key string) {
+	var photo models.Photo
+	if err := db.Select("id, like_count").Where("id = ?", photoID).First(&photo).Error; err != nil {
+		fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "Success",
+		"message": translate(c, key),
+		"data": gin.H{
+			"photo_id":    photo.ID,
+			"like_count":  photo.LikeCount,
+			"liked_by_me": liked,
+		},
+	})
+}
+
+// attachLikedByMe marks the photos liked by viewerID using a single query.
+func attachLikedByMe(db *gorm.DB, photos []models.Photo, viewerID string) error {
+	if viewerID == "" || len(photos) == 0 {
+		return nil
+	}
+
+	ids := make([]int, len(photos))
+	for i := range photos {
+		ids[i] = photos[i].ID
+	}
+	liked := []int{}
+	if err := db.Model(&models.Like{}).Where("user_id = ? AND photo_id IN (?)", viewerID, ids).Pluck("photo_id", &liked).Error; err != nil {
+		return err
+	}
+
+	set := make(map[int]bool, len(liked))
+	for _, id := range liked {
+		set[id] = true
+	}
+	for i := range photos {
+		photos[i].LikedByMe = set[photos[i].ID]
+	}
+	return nil
+}
+
+// recountLikes recounts the likes of the photos a user liked, leaving out deleted users.
+// It runs when the user is deleted or restored.
+func recountLikes(db *gorm.DB, userID string) error {
+	liked := db.New().Model(&models.Like{}).Select("photo_id").Where("user_id = ?", userID).SubQuery()
+	live := db.New().Table("likes").Select("COUNT(*)").Joins("JOIN users ON users.id = likes.user_id").
+		Where("likes.photo_id = photos.id AND users.deleted_at IS NULL").SubQuery()
+	return db.Unscoped().Model(&models.Photo{}).Where("id IN ?", liked).UpdateColumn("like_count", live).Error
+}
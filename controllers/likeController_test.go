@@ -0,0 +1,83 @@
+package controllers_test
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+
+	"task-5-pbi-btpns-arthagusfiputra/models"
+)
+
+// TestLikeIdempotent checks that repeated likes and unlikes count once, including when sent at once,
+// and that like_count always matches the stored likes.
+func TestLikeIdempotent(t *testing.T) {
+	h, db := newServer(t)
+	alice, _ := seedUser(t, db, "alice")
+	_, bobToken := seedUser(t, db, "bob")
+	_, carolToken := seedUser(t, db, "carol")
+	_, daveToken := seedUser(t, db, "dave")
+	photo := seedPhoto(t, db, alice)
+	path := "/photos/" + strconv.Itoa(photo.ID) + "/like"
+
+	stored := func() (int, int) {
+		t.Helper()
+		var stored models.Photo
+		if err := db.Where("id = ?", photo.ID).First(&stored).Error; err != nil {
+			t.Fatal(err)
+		}
+		var likes int
+		if err := db.Model(&models.Like{}).Where("photo_id = ?", photo.ID).Count(&likes).Error; err != nil {
+			t.Fatal(err)
+		}
+		return stored.LikeCount, likes
+	}
+
+	steps := []struct {
+		name   string
+		method string
+		token  string
+		want   int
+		liked  bool
+	}{
+		{"bob likes", http.MethodPost, bobToken, 1, true},
+		{"bob likes again", http.MethodPost, bobToken, 1, true},
+		{"carol likes", http.MethodPost, carolToken, 2, true},
+		{"bob unlikes", http.MethodDelete, bobToken, 1, false},
+		{"bob unlikes again", http.MethodDelete, bobToken, 1, false},
+		{"dave unlikes without a like", http.MethodDelete, daveToken, 1, false},
+		{"bob likes once more", http.MethodPost, bobToken, 2, true},
+	}
+	for _, step := range steps {
+		code, body := call(h, step.method, path, step.token, nil)
+		if code != http.StatusOK {
+			t.Fatalf("%s: got status %d: %v", step.name, code, body)
+		}
+		data := body["data"].(map[string]interface{})
+		if int(data["like_count"].(float64)) != step.want || data["liked_by_me"] != step.liked {
+			t.Errorf("%s: got %v, want like_count %d and liked_by_me %v", step.name, data, step.want, step.liked)
+		}
+		if count, likes := stored(); count != step.want || likes != step.want {
+			t.Errorf("%s: stored like_count %d with %d likes, want %d", step.name, count, likes, step.want)
+		}
+	}
+
+	// Parallel likes of one user count once, parallel unlikes remove it once
+	for _, method := range []string{http.MethodPost, http.MethodDelete} {
+		codes := parallel(10, func(int) int {
+			code, _ := call(h, method, path, daveToken, nil)
+			return code
+		})
+		for i, code := range codes {
+			if code != http.StatusOK {
+				t.Errorf("parallel %s %d: got status %d", method, i, code)
+			}
+		}
+		want := 2
+		if method == http.MethodPost {
+			want = 3
+		}
+		if count, likes := stored(); count != want || likes != want {
+			t.Errorf("parallel %s: stored like_count %d with %d likes, want %d", method, count, likes, want)
+		}
+	}
+}
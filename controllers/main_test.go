@@ -0,0 +1,206 @@
+package controllers_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/png"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"task-5-pbi-btpns-arthagusfiputra/app/auth"
+	"task-5-pbi-btpns-arthagusfiputra/app/remote"
+	"task-5-pbi-btpns-arthagusfiputra/app/search"
+	"task-5-pbi-btpns-arthagusfiputra/app/storage"
+	"task-5-pbi-btpns-arthagusfiputra/controllers"
+	"task-5-pbi-btpns-arthagusfiputra/database"
+	"task-5-pbi-btpns-arthagusfiputra/helpers/logging"
+	"task-5-pbi-btpns-arthagusfiputra/middlewares"
+	"task-5-pbi-btpns-arthagusfiputra/models"
+	"task-5-pbi-btpns-arthagusfiputra/router"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+)
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	os.Setenv("LOG_LEVEL", "error")
+	os.Setenv("FILE_URL_SECRET", "test-file-url-secret")
+
+	dir, err := os.MkdirTemp("", "photos")
+	if err != nil {
+		panic(err)
+	}
+	os.Setenv("STORAGE_DIR", dir)
+
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// openDB returns a migrated in-memory SQLite database, closed when the test ends.
+// A single connection keeps every statement on the same in-memory database.
+func openDB(tb testing.TB) *gorm.DB {
+	tb.Helper()
+	db, err := gorm.Open("sqlite3", ":memory:")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	db.DB().SetMaxOpenConns(1)
+	db.SetLogger(logging.SQLLogger{Logger: logging.New(os.Stderr)})
+	tb.Cleanup(func() { db.Close() })
+
+	if err := database.Migrate(db); err != nil {
+		tb.Fatal(err)
+	}
+	return db
+}
+
+// newServer returns the full API on a fresh database.
+func newServer(tb testing.TB) (http.Handler, *gorm.DB) {
+	db := openDB(tb)
+	return router.InitRoutes(db), db
+}
+
+// newHandler serves a single handler on db, without the background workers started by the router,
+// so the statements counted during a request are the request's own.
+func newHandler(db *gorm.DB, method string, path string, handler gin.HandlerFunc) http.Handler {
+	r := gin.New()
+	r.Use(middlewares.Locale(), middlewares.ErrorHandler(), func(c *gin.Context) {
+		c.Set("db", db)
+		c.Set("storage", storage.Default())
+	})
+	r.Handle(method, path, handler)
+	return r
+}
+
+// seedUser inserts a user directly, skipping the bcrypt hashing of registration, and returns a token for it.
+func seedUser(tb testing.TB, db *gorm.DB, name string) (models.User, string) {
+	tb.Helper()
+	user := models.User{ID: uuid.New().String(), Username: name, Email: name + "@example.com", Password: "-", Version: 1}
+	if err := db.Create(&user).Error; err != nil {
+		tb.Fatal(err)
+	}
+	token, err := auth.GenerateJWT(user.Email, user.Username)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return user, token
+}
+
+// seedPhoto inserts a public photo owned by user.
+func seedPhoto(tb testing.TB, db *gorm.DB, user models.User) models.Photo {
+	tb.Helper()
+	photo := models.Photo{Title: "Photo of " + user.Username, Caption: "caption", PhotoUrl: "https://example.com/" + user.ID + ".jpg", UserID: user.ID, Visibility: "public", Version: 1}
+	if err := db.Create(&photo).Error; err != nil {
+		tb.Fatal(err)
+	}
+	return photo
+}
+
+// seedUsers inserts n users with a photo each in one transaction.
+func seedUsers(tb testing.TB, db *gorm.DB, n int) []models.User {
+	tb.Helper()
+	users := make([]models.User, 0, n)
+	tx := db.Begin()
+	for i := 0; i < n; i++ {
+		user, _ := seedUser(tb, tx, fmt.Sprintf("user%d", i))
+		seedPhoto(tb, tx, user)
+		users = append(users, user)
+	}
+	if err := tx.Commit().Error; err != nil {
+		tb.Fatal(err)
+	}
+	return users
+}
+
+// call sends a JSON request and returns the status and the decoded envelope.
+// It doesn't fail the test itself, so it can be used from other goroutines.
+func call(h http.Handler, method string, path string, token string, body interface{}) (int, map[string]interface{}) {
+	code, _, envelope := callWithHeader(h, method, path, token, nil, body)
+	return code, envelope
+}
+
+// callWithHeader is call with extra request headers, also returning the response headers.
+func callWithHeader(h http.Handler, method string, path string, token string, header http.Header, body interface{}) (int, http.Header, map[string]interface{}) {
+	var buf bytes.Buffer
+	if body != nil {
+		json.NewEncoder(&buf).Encode(body)
+	}
+	req := httptest.NewRequest(method, path, &buf)
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	envelope := map[string]interface{}{}
+	json.Unmarshal(w.Body.Bytes(), &envelope)
+	return w.Code, w.Header(), envelope
+}
+
+// countQueries counts the SELECT statements run on db from now on.
+func countQueries(db *gorm.DB) *int64 {
+	var count int64
+	inc := func(*gorm.Scope) { atomic.AddInt64(&count, 1) }
+	db.Callback().Query().After("gorm:query").Register("test:count_query", inc)
+	db.Callback().RowQuery().After("gorm:row_query").Register("test:count_row_query", inc)
+	return &count
+}
+
+// imageServer serves a 1x1 PNG at every path and lets photos be ingested from it until the test ends.
+func imageServer(tb testing.TB) *httptest.Server {
+	tb.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 1, 1))); err != nil {
+		tb.Fatal(err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(buf.Bytes())
+	}))
+
+	fetcher := remote.New()
+	fetcher.AllowIP = func(ip net.IP) bool { return ip.IsLoopback() }
+	restore := controllers.UsePhotoFetcher(fetcher)
+	tb.Cleanup(func() {
+		restore()
+		server.Close()
+	})
+	return server
+}
+
+// storedFiles lists the files kept in storage for the photos of a user.
+func storedFiles(tb testing.TB, userID string) []string {
+	tb.Helper()
+	entries, err := os.ReadDir(filepath.Join(os.Getenv("STORAGE_DIR"), "photos", userID))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		tb.Fatal(err)
+	}
+	names := make([]string, len(entries))
+	for i, entry := range entries {
+		names[i] = "photos/" + userID + "/" + entry.Name()
+	}
+	return names
+}
+
+// freshSearchIndex gives the test an empty in-memory search index, loaded from the test database on the first search.
+func freshSearchIndex(tb testing.TB) {
+	models.PhotoIndex = search.NewIndex()
+	tb.Cleanup(func() { models.PhotoIndex = search.NewIndex() })
+}
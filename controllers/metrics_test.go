@@ -0,0 +1,105 @@
+package controllers_test
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// TestMetricsNeedToken checks that /metrics is only served to a scraper sending METRICS_TOKEN, and to nobody without one.
+func TestMetricsNeedToken(t *testing.T) {
+	t.Run("unset", func(t *testing.T) {
+		t.Setenv("METRICS_TOKEN", "")
+		h, db := newServer(t)
+		_, userToken := seedUser(t, db, "alice")
+
+		cases := []struct {
+			name  string
+			token string
+		}{
+			{"no token", ""},
+			{"user token", userToken},
+			{"any token", "scrape-secret"},
+		}
+		for _, tc := range cases {
+			if code, _ := call(h, http.MethodGet, "/metrics", tc.token, nil); code != http.StatusUnauthorized {
+				t.Errorf("%s: got status %d, want %d", tc.name, code, http.StatusUnauthorized)
+			}
+		}
+	})
+
+	t.Run("set", func(t *testing.T) {
+		t.Setenv("METRICS_TOKEN", "scrape-secret")
+		h, db := newServer(t)
+		_, userToken := seedUser(t, db, "alice")
+
+		cases := []struct {
+			name  string
+			token string
+			want  int
+		}{
+			{"no token", "", http.StatusUnauthorized},
+			{"user token", userToken, http.StatusUnauthorized},
+			{"wrong token", "scrape-secreT", http.StatusUnauthorized},
+			{"metrics token", "scrape-secret", http.StatusOK},
+		}
+		for _, tc := range cases {
+			if code, _ := call(h, http.MethodGet, "/metrics", tc.token, nil); code != tc.want {
+				t.Errorf("%s: got status %d, want %d", tc.name, code, tc.want)
+			}
+		}
+	})
+}
+
+// TestMetricsRecordRequests checks that requests are counted by their route template.
+func TestMetricsRecordRequests(t *testing.T) {
+	t.Setenv("METRICS_TOKEN", "scrape-secret")
+	h, db := newServer(t)
+	alice, _ := seedUser(t, db, "alice")
+	photo := seedPhoto(t, db, alice)
+
+	// scrape returns the value of the sample named exactly by series, or 0 before it is first recorded
+	scrape := func(series string) float64 {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		req.Header.Set("Authorization", "Bearer scrape-secret")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("scrape: got status %d", w.Code)
+		}
+		scanner := bufio.NewScanner(w.Body)
+		for scanner.Scan() {
+			if value, ok := strings.CutPrefix(scanner.Text(), series+" "); ok {
+				f, err := strconv.ParseFloat(value, 64)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return f
+			}
+		}
+		return 0
+	}
+
+	found := `http_requests_total{method="GET",route="/photos/:photoId",status="200"}`
+	missing := `http_requests_total{method="GET",route="/photos/:photoId",status="404"}`
+	beforeFound, beforeMissing := scrape(found), scrape(missing)
+
+	for i := 0; i < 3; i++ {
+		call(h, http.MethodGet, "/photos/"+strconv.Itoa(photo.ID), "", nil)
+	}
+	call(h, http.MethodGet, "/photos/999999", "", nil)
+
+	if got := scrape(found) - beforeFound; got != 3 {
+		t.Errorf("got %v more requests for %s, want 3", got, found)
+	}
+	if got := scrape(missing) - beforeMissing; got != 1 {
+		t.Errorf("got %v more requests for %s, want 1", got, missing)
+	}
+	if count := scrape(`http_request_duration_seconds_count{method="GET",route="/photos/:photoId",status="200"}`); count < 3 {
+		t.Errorf("got %v timed requests, want at least 3", count)
+	}
+}
@@ -0,0 +1,235 @@
+package controllers
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"task-5-pbi-btpns-arthagusfiputra/app/events"
+	"task-5-pbi-btpns-arthagusfiputra/helpers/apperror"
+	"task-5-pbi-btpns-arthagusfiputra/helpers/pagination"
+	"task-5-pbi-btpns-arthagusfiputra/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+)
+
+// GetNotifications retrieves the notifications of the logged in user, most recently active first.
+// Pass unread=true to only list the unread ones.
+func GetNotifications(c *gin.Context) {
+	// Set the database
+	db := c.MustGet("db").(*gorm.DB)
+
+	userHasLogin, ok := currentUser(c, db)
+	if !ok {
+		return
+	}
+
+	var unreadCount int
+	err := db.Model(&models.Notification{}).Where("user_id = ? AND read_at IS NULL", userHasLogin.ID).Count(&unreadCount).Error
+
+	query := db.Where("user_id = ?", userHasLogin.ID)
+	if c.Query("unread") == "true" {
+		query = query.Where("read_at IS NULL")
+	}
+	limit, perr := pagination.ParseLimit(c.Query("limit"))
+	if perr == nil && c.Query("cursor") != "" {
+		var cursor pagination.Cursor
+		cursor, perr = pagination.Decode(c.Query("cursor"))
+		var after time.Time
+		if perr == nil {
+			after, perr = time.Parse(time.RFC3339Nano, cursor.Value)
+		}
+		if perr != nil || cursor.Sort != "notifications" {
+			perr = apperror.Field("cursor", "CURSOR_INVALID")
+		} else {
+			query = query.Where("(updated_at < ?) OR (updated_at = ? AND id < ?)", after, after, cursor.ID)
+		}
+	}
+	if perr != nil {
+		fail(c, apperror.Invalid(perr))
+		return
+	}
+
+	notifications := []models.Notification{}
+	if err == nil {
+		err = query.Order("updated_at desc").Order("id desc").Limit(limit + 1).Find(&notifications).Error
+	}
+	if err == nil {
+		err = describeNotifications(db, notifications, c.GetString("locale"))
+	}
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	paging := pagination.Paging{Limit: limit}
+	if len(notifications) > limit {
+		notifications = notifications[:limit]
+		last := notifications[limit-1]
+		paging.HasMore = true
+		paging.NextCursor = pagination.Cursor{Sort: "notifications", Value: last.UpdatedAt.Format(time.RFC3339Nano), ID: last.ID}.Encode()
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":       "Success",
+		"message":      translate(c, "DATA_RETRIEVED"),
+		"data":         notifications,
+		"unread_count": unreadCount,
+		"paging":       paging,
+	})
+}
+
+// MarkNotificationRead marks a single notification of the logged in user as read.
+func MarkNotificationRead(c *gin.Context) {
+	// Set the database
+	db := c.MustGet("db").(*gorm.DB)
+
+	userHasLogin, ok := currentUser(c, db)
+	if !ok {
+		return
+	}
+
+	var notification models.Notification
+	if err := db.Where("id = ? AND user_id = ?", c.Param("notificationId"), userHasLogin.ID).First(&notification).Error; err != nil {
+		fail(c, apperror.New(apperror.NotificationNotFound).With("id", c.Param("notificationId")))
+		return
+	}
+
+	if notification.ReadAt == nil {
+		if err := db.Model(&notification).UpdateColumn("read_at", time.Now()).Error; err != nil {
+			fail(c, err)
+			return
+		}
+	}
+
+	respondUnread(c, db, userHasLogin.ID, "NOTIFICATION_READ")
+}
+
+// MarkAllNotificationsRead marks every notification of the logged in user as read.
+func MarkAllNotificationsRead(c *gin.Context) {
+	// Set the database
+	db := c.MustGet("db").(*gorm.DB)
+
+	userHasLogin, ok := currentUser(c, db)
+	if !ok {
+		return
+	}
+
+	err := db.Model(&models.Notification{}).Where("user_id = ? AND read_at IS NULL", userHasLogin.ID).
+		UpdateColumn("read_at", time.Now()).Error
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	respondUnread(c, db, userHasLogin.ID, "NOTIFICATIONS_READ")
+}
+
+// RecordNotifications returns the event handler that turns events into notifications.
+// An event joins the unread notification of the same kind about the same subject when there is one.
+// Every recorded notification is published back on the bus.
+func RecordNotifications(db *gorm.DB, bus *events.Bus) events.Handler {
+	return func(e events.Event) {
+		// Nobody is notified about their own actions
+		if e.UserID == "" || e.UserID == e.ActorID {
+			return
+		}
+
+		notification := models.Notification{UserID: e.UserID, Type: e.Type, ActorID: e.ActorID, ActorCount: 1}
+		switch e.Type {
+		case events.PhotoLiked, events.PhotoCommented:
+			notification.PhotoID = &e.PhotoID
+		case events.CommentReplied:
+			notification.PhotoID = &e.PhotoID
+			notification.CommentID = &e.CommentID
+		case events.UserFollowed:
+		default:
+			return
+		}
+
+		err := inTransaction(db, func(tx *gorm.DB) error {
+			group := tx.Where("user_id = ? AND type = ? AND read_at IS NULL", e.UserID, e.Type)
+			if notification.PhotoID != nil {
+				group = group.Where("photo_id = ?", *notification.PhotoID)
+			}
+			if notification.CommentID != nil {
+				group = group.Where("comment_id = ?", *notification.CommentID)
+			}
+
+			var existing models.Notification
+			if err := group.Order("id desc").First(&existing).Error; gorm.IsRecordNotFoundError(err) {
+				if err := tx.Create(&notification).Error; err != nil {
+					return err
+				}
+				return tx.Create(&models.NotificationActor{NotificationID: notification.ID, UserID: e.ActorID}).Error
+			} else if err != nil {
+				return err
+			}
+			notification.ID = existing.ID
+
+			// Someone already counted in the group only moves it to the top again
+			actor := models.NotificationActor{NotificationID: existing.ID, UserID: e.ActorID}
+			updates := map[string]interface{}{"actor_id": e.ActorID, "updated_at": e.At}
+			if tx.Where(&actor).First(&models.NotificationActor{}).RecordNotFound() {
+				if err := tx.Create(&actor).Error; err != nil {
+					return err
+				}
+				updates["actor_count"] = gorm.Expr("actor_count + 1")
+			}
+			return tx.Model(&existing).UpdateColumns(updates).Error
+		})
+		if err != nil {
+			slog.Error("recording notification", "event", e.Type, "error", err)
+			return
+		}
+
+		// Publish the notification as it now reads, in the language of the recipient
+		var recipient models.User
+		recorded := []models.Notification{}
+		err = db.Select("locale").Where("id = ?", e.UserID).First(&recipient).Error
+		if err == nil {
+			err = db.Where("id = ?", notification.ID).Find(&recorded).Error
+		}
+		if err == nil {
+			err = describeNotifications(db, recorded, recipient.Locale)
+		}
+		if err != nil || len(recorded) == 0 {
+			slog.Error("loading notification", "notification_id", notification.ID, "error", err)
+			return
+		}
+		bus.Publish(events.Event{Type: events.NotificationRecorded, UserID: e.UserID, Data: recorded[0]})
+	}
+}
+
+// describeNotifications attaches the latest actor and the message in locale of every notification.
+func describeNotifications(db *gorm.DB, notifications []models.Notification, locale string) error {
+	userIDs := make([]string, len(notifications))
+	for i := range notifications {
+		userIDs[i] = notifications[i].ActorID
+	}
+	owners, err := loadOwners(db, userIDs)
+	if err != nil {
+		return err
+	}
+	for i := range notifications {
+		notifications[i].Actor = owners[notifications[i].ActorID]
+		notifications[i].Describe(locale)
+	}
+	return nil
+}
+
+// respondUnread writes the unread notification count of a user as a success response.
+func respondUnread(c *gin.Context, db *gorm.DB, userID string, key string) {
+	var unreadCount int
+	if err := db.Model(&models.Notification{}).Where("user_id = ? AND read_at IS NULL", userID).Count(&unreadCount).Error; err != nil {
+		fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "Success",
+		"message": translate(c, key),
+		"data":    gin.H{"unread_count": unreadCount},
+	})
+}
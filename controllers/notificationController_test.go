@@ -0,0 +1,83 @@
+package controllers_test
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"task-5-pbi-btpns-arthagusfiputra/models"
+)
+
+// TestNotificationCoalescing checks that events about the same subject join one unread notification,
+// that an actor is counted once however often they act, and that a read notification starts a new group.
+func TestNotificationCoalescing(t *testing.T) {
+	h, db := newServer(t)
+	alice, aliceToken := seedUser(t, db, "alice")
+	bob, bobToken := seedUser(t, db, "bob")
+	carol, carolToken := seedUser(t, db, "carol")
+	first := seedPhoto(t, db, alice)
+	second := seedPhoto(t, db, alice)
+	like := func(photo models.Photo) string { return "/photos/" + strconv.Itoa(photo.ID) + "/like" }
+
+	// summary lists the notifications of alice, most recently active first, as type/photo/latest actor/actor count
+	summary := func() []string {
+		t.Helper()
+		code, body := call(h, http.MethodGet, "/notifications", aliceToken, nil)
+		if code != http.StatusOK {
+			t.Fatalf("got status %d: %v", code, body)
+		}
+		lines := []string{}
+		for _, item := range body["data"].([]interface{}) {
+			n := item.(map[string]interface{})
+			actor := n["actor"].(map[string]interface{})
+			lines = append(lines, fmt.Sprintf("%v/%v/%v/%v", n["type"], n["photo_id"], actor["username"], n["actor_count"]))
+		}
+		return lines
+	}
+	liked := func(photo models.Photo, actor string, count int) string {
+		return fmt.Sprintf("photo.liked/%d/%s/%d", photo.ID, actor, count)
+	}
+
+	steps := []struct {
+		name   string
+		method string
+		path   string
+		token  string
+		want   []string
+	}{
+		{"bob likes", http.MethodPost, like(first), bobToken, []string{liked(first, "bob", 1)}},
+		{"carol likes", http.MethodPost, like(first), carolToken, []string{liked(first, "carol", 2)}},
+		{"bob unlikes", http.MethodDelete, like(first), bobToken, []string{liked(first, "carol", 2)}},
+		{"bob likes again", http.MethodPost, like(first), bobToken, []string{liked(first, "bob", 2)}},
+		{"alice likes her own photo", http.MethodPost, like(first), aliceToken, []string{liked(first, "bob", 2)}},
+		{"bob likes another photo", http.MethodPost, like(second), bobToken, []string{liked(second, "bob", 1), liked(first, "bob", 2)}},
+		{"carol follows", http.MethodPost, "/users/" + alice.ID + "/follow", carolToken, []string{"user.followed/<nil>/carol/1", liked(second, "bob", 1), liked(first, "bob", 2)}},
+		{"alice reads everything", http.MethodPut, "/notifications/read", aliceToken, []string{"user.followed/<nil>/carol/1", liked(second, "bob", 1), liked(first, "bob", 2)}},
+		{"carol unlikes", http.MethodDelete, like(first), carolToken, []string{"user.followed/<nil>/carol/1", liked(second, "bob", 1), liked(first, "bob", 2)}},
+		{"carol likes after the read", http.MethodPost, like(first), carolToken, []string{liked(first, "carol", 1), "user.followed/<nil>/carol/1", liked(second, "bob", 1), liked(first, "bob", 2)}},
+	}
+	for _, step := range steps {
+		if code, body := call(h, step.method, step.path, step.token, nil); code != http.StatusOK && code != http.StatusCreated {
+			t.Fatalf("%s: got status %d: %v", step.name, code, body)
+		}
+		if got := summary(); fmt.Sprint(got) != fmt.Sprint(step.want) {
+			t.Errorf("%s: got notifications %v, want %v", step.name, got, step.want)
+		}
+	}
+
+	// Every actor of a group is recorded once
+	var actors []string
+	if err := db.Model(&models.NotificationActor{}).Joins("JOIN notifications ON notifications.id = notification_actors.notification_id").
+		Where("notifications.photo_id = ? AND notifications.read_at IS NOT NULL", first.ID).
+		Order("notification_actors.user_id").Pluck("notification_actors.user_id", &actors).Error; err != nil {
+		t.Fatal(err)
+	}
+	want := []string{bob.ID, carol.ID}
+	if want[0] > want[1] {
+		want[0], want[1] = want[1], want[0]
+	}
+	if fmt.Sprint(actors) != fmt.Sprint(want) {
+		t.Errorf("got actors %v of the read group, want %v", actors, want)
+	}
+}
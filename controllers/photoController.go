@@ -1,188 +1,233 @@
 package controllers
 
 import (
-	"encoding/json"
-	"io/ioutil"
+	"bytes"
+	"html"
 	"net/http"
 	"strings"
 	"task-5-pbi-btpns-arthagusfiputra/app"
-	"task-5-pbi-btpns-arthagusfiputra/app/auth"
-	errorformat "task-5-pbi-btpns-arthagusfiputra/helpers/error"
+	"task-5-pbi-btpns-arthagusfiputra/app/events"
+	"task-5-pbi-btpns-arthagusfiputra/app/remote"
+	"task-5-pbi-btpns-arthagusfiputra/app/storage"
+	"task-5-pbi-btpns-arthagusfiputra/helpers/apperror"
+	"task-5-pbi-btpns-arthagusfiputra/helpers/metrics"
 	"task-5-pbi-btpns-arthagusfiputra/models"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"github.com/jinzhu/gorm"
 )
 
-// GetPhoto retrieves a list of photo profiles.
+// photoFetcher downloads photos submitted by URL.
+var photoFetcher = remote.New()
+
+// GetPhoto retrieves a page of photo profiles.
 func GetPhoto(c *gin.Context) {
+	// Read the paging, sorting and filter options
+	query, err := parsePhotoQuery(c)
+	if err != nil {
+		fail(c, apperror.Invalid(err))
+		return
+	}
+
 	// Create a list of photos
 	photos := []models.Photo{}
 
 	// Set the database
 	db := c.MustGet("db").(*gorm.DB)
-	if err := db.Debug().Model(&models.Photo{}).Limit(100).Find(&photos).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"status":  "Error",
-			"message": "Photo not found",
-			"data":    nil,
-		})
+	scoped := db.Model(&models.Photo{}).Scopes(visiblePhotos(viewerID(c, db), false))
+	if err := query.apply(scoped).Find(&photos).Error; err != nil {
+		fail(c, err)
 		return
 	}
+	paging := query.page(&photos)
 
-	// Initialize the list of photos
-	if len(photos) > 0 {
-		for i := range photos {
-			user := models.User{}
-			err := db.Model(&models.User{}).Where("id = ?", photos[i].UserID).Take(&user).Error
-
-			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{
-					"status":  "Error",
-					"message": err.Error(),
-					"data":    nil,
-				})
-				return
-			}
-
-			photos[i].Owner = app.Owner{
-				ID:       user.ID,
-				Username: user.Username,
-				Email:    user.Email,
-			}
-		}
+	// Attach the owners with a single query
+	err = attachOwners(db, photos)
+	if err == nil {
+		err = presentPhotos(db, photos, viewerID(c, db))
+	}
+	if err != nil {
+		fail(c, err)
+		return
 	}
 
 	// Return the response
 	c.JSON(http.StatusOK, gin.H{
 		"status":  "Success",
-		"message": "Data retrieved successfully",
+		"message": translate(c, "DATA_RETRIEVED"),
 		"data":    photos,
+		"paging":  paging,
 	})
 }
 
-// CreatePhoto creates a new photo profile.
-func CreatePhoto(c *gin.Context) {
+// GetPhotoByID retrieves a single photo profile.
+func GetPhotoByID(c *gin.Context) {
 	// Set the database
 	db := c.MustGet("db").(*gorm.DB)
 
-	// Get the bearer token
-	tokenString := c.GetHeader("Authorization")
-	if tokenString == "" {
-		c.JSON(401, gin.H{"error": "Token not found"})
+	// Check if the photo exists and is visible to the caller
+	var photo models.Photo
+	scoped := db.Scopes(visiblePhotos(viewerID(c, db), true))
+	if err := scoped.Where("photos.id = ?", c.Param("photoId")).First(&photo).Error; err != nil {
+		fail(c, apperror.New(apperror.PhotoNotFound).With("id", c.Param("photoId")))
 		return
 	}
 
-	// Get the user email from JWT
-	email, err := auth.GetEmail(strings.Split(tokenString, "Bearer ")[1])
+	photos := []models.Photo{photo}
+	err := attachOwners(db, photos)
+	if err == nil {
+		err = presentPhotos(db, photos, viewerID(c, db))
+	}
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"status":  "Error",
-			"message": err.Error(),
-			"data":    nil,
-		})
+		fail(c, err)
+		return
 	}
 
-	// Get user data from the database
-	var userHasLogin models.User
+	if notModified(c, photos[0].Version, photos[0]) {
+		return
+	}
 
-	err = db.Debug().Where("email = ?", email).First(&userHasLogin).Error
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"status":  "Error",
-			"message": "User with email " + email + " not found",
-			"data":    nil,
-		})
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "Success",
+		"message": translate(c, "DATA_RETRIEVED"),
+		"data":    photos[0],
+	})
+}
+
+// GetUserPhotos retrieves a page of the photos of one user.
+func GetUserPhotos(c *gin.Context) {
+	// Set the database
+	db := c.MustGet("db").(*gorm.DB)
+
+	// Check if the user exists
+	var user models.User
+	if err := db.Where("id = ?", c.Param("userId")).First(&user).Error; err != nil {
+		fail(c, apperror.New(apperror.UserNotFound).With("id", c.Param("userId")))
 		return
 	}
 
-	// Read the request body
-	body, err := ioutil.ReadAll(c.Request.Body)
+	// Read the paging and sorting options, the user comes from the path
+	query, err := parsePhotoQuery(c)
 	if err != nil {
-		c.JSON(http.StatusUnprocessableEntity, gin.H{
-			"status":  "Error",
-			"message": err.Error(),
-			"data":    nil,
-		})
+		fail(c, apperror.Invalid(err))
+		return
 	}
+	query.UserID = user.ID
 
-	// Convert JSON to an object
-	inputPhoto := models.Photo{}
-	err = json.Unmarshal(body, &inputPhoto)
-	if err != nil {
-		c.JSON(http.StatusUnprocessableEntity, gin.H{
-			"status":  "Error",
-			"message": err.Error(),
-			"data":    nil,
-		})
+	photos := []models.Photo{}
+	scoped := db.Model(&models.Photo{}).Scopes(visiblePhotos(viewerID(c, db), false))
+	if err := query.apply(scoped).Find(&photos).Error; err != nil {
+		fail(c, err)
+		return
+	}
+	paging := query.page(&photos)
+
+	owner := app.Owner{
+		ID:       user.ID,
+		Username: user.Username,
+	}
+	for i := range photos {
+		photos[i].Owner = owner
+	}
+	if err := presentPhotos(db, photos, viewerID(c, db)); err != nil {
+		fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "Success",
+		"message": translate(c, "DATA_RETRIEVED"),
+		"data":    photos,
+		"paging":  paging,
+	})
+}
+
+// CreatePhoto creates a new photo profile.
+func CreatePhoto(c *gin.Context) {
+	// Set the database
+	db := c.MustGet("db").(*gorm.DB)
+
+	// Get the logged in user
+	userHasLogin, ok := currentUser(c, db)
+	if !ok {
+		return
+	}
+
+	var input app.PhotoInput
+	if !bindJSON(c, &input) {
 		return
 	}
 
 	// Initialize the photo
+	inputPhoto := photoFromInput(input)
 	inputPhoto.Init()
 	inputPhoto.UserID = userHasLogin.ID
 	inputPhoto.Owner = app.Owner{
 		ID:       userHasLogin.ID,
 		Username: userHasLogin.Username,
-		Email:    userHasLogin.Email,
 	}
-	err = inputPhoto.Validate("upload") // Validate the photo
-	if err != nil {
-		c.JSON(http.StatusUnprocessableEntity, gin.H{
-			"status":  "Error",
-			"message": err.Error(),
-			"data":    nil,
-		})
+	if _, err := models.NormalizeTags(inputPhoto.Tags); err != nil {
+		fail(c, apperror.Invalid(err))
 		return
 	}
 
-	// Check if the photo already exists
-	var oldPhoto models.Photo
-	err = db.Debug().Model(&models.Photo{}).Where("user_id = ?", userHasLogin.ID).Find(&oldPhoto).Error
-	if err != nil {
-		if err.Error() == "Data not found" {
-			err = db.Debug().Create(&inputPhoto).Error // Create the photo in the database
-			if err != nil {
-				formattedError := errorformat.ErrorMessage(err.Error())
-				c.JSON(http.StatusInternalServerError, gin.H{
-					"status":  "Error",
-					"message": formattedError.Error(),
-					"data":    nil,
-				})
-				return
-			}
-			c.JSON(http.StatusOK, gin.H{
-				"status":  "Success",
-				"message": "Photo uploaded successfully",
-				"data":    inputPhoto,
-			})
-			return
-		}
-		c.JSON(http.StatusUnprocessableEntity, gin.H{
-			"status":  "Error",
-			"message": err.Error(),
-			"data":    nil,
-		})
+	// Fetch the image into our storage when a source URL is given
+	if !ingestPhoto(c, &inputPhoto, userHasLogin.ID) {
 		return
 	}
 
-	// Update the photo with new data
-	inputPhoto.ID = oldPhoto.ID
-	err = db.Debug().Model(&oldPhoto).Updates(&inputPhoto).Error
+	// Create the photo, or update it when the user already has one.
+	// Uploads of the same user wait for each other on the user row, so only the first one creates it.
+	var photo models.Photo
+	created := false
+	replaced := ""
+	err := inTransaction(db, func(tx *gorm.DB) error {
+		photo, created, replaced = inputPhoto, false, ""
+		if _, err := lockUser(tx, userHasLogin.ID); err != nil {
+			return err
+		}
+
+		var oldPhoto models.Photo
+		err := tx.Where("user_id = ?", userHasLogin.ID).First(&oldPhoto).Error
+		if gorm.IsRecordNotFoundError(err) {
+			created = true
+			if err := tx.Create(&photo).Error; err != nil {
+				return err
+			}
+			return applyPhotoTags(tx, &photo)
+		}
+		if err != nil {
+			return err
+		}
+
+		photo.ID = oldPhoto.ID
+		replaced, err = savePhoto(tx, &oldPhoto, &photo)
+		return err
+	})
 	if err != nil {
-		formattedError := errorformat.ErrorMessage(err.Error())
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"status":  "Error",
-			"message": formattedError.Error(),
-			"data":    nil,
+		removeFile(c, inputPhoto.FileKey) // A file fetched for this upload isn't used by any photo
+		fail(c, err)
+		return
+	}
+	removeFile(c, replaced)
+
+	photo.SignURL()
+	if created {
+		publish(c, events.Event{Type: events.PhotoCreated, ActorID: userHasLogin.ID, UserID: userHasLogin.ID, PhotoID: photo.ID, Data: photo})
+		c.JSON(http.StatusOK, gin.H{
+			"status":  "Success",
+			"message": translate(c, "PHOTO_UPLOADED"),
+			"data":    photo,
 		})
 		return
 	}
+	publish(c, events.Event{Type: events.PhotoUpdated, ActorID: userHasLogin.ID, UserID: userHasLogin.ID, PhotoID: photo.ID, Data: photo})
 
 	c.JSON(http.StatusOK, gin.H{
 		"status":  "Success",
-		"message": "Photo changed successfully",
-		"data":    inputPhoto,
+		"message": translate(c, "PHOTO_CHANGED"),
+		"data":    photo,
 	}) // Return the response
 }
 
@@ -191,112 +236,153 @@ func UpdatePhoto(c *gin.Context) {
 	// Set the database
 	db := c.MustGet("db").(*gorm.DB)
 
-	// Get the bearer token
-	tokenString := c.GetHeader("Authorization")
-	if tokenString == "" {
-		c.JSON(401, gin.H{"error": "Token not found"})
+	// Get the logged in user
+	userHasLogin, ok := currentUser(c, db)
+	if !ok {
 		return
 	}
 
-	// Get the user email from JWT
-	email, err := auth.GetEmail(strings.Split(tokenString, "Bearer ")[1])
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"status":  "Error",
-			"message": err.Error(),
-			"data":    nil,
-		})
+	var input app.PhotoInput
+	if !bindJSON(c, &input) {
+		return
+	}
+	photoInput := photoFromInput(input)
+	photoInput.Init()
+	if _, err := models.NormalizeTags(photoInput.Tags); err != nil {
+		fail(c, apperror.Invalid(err))
+		return
 	}
 
-	// Get user data from JWT
-	var userHasLogin models.User
+	// Check if the photo already exists
+	var photo models.Photo
+	if err := db.Where("id = ?", c.Param("photoId")).First(&photo).Error; err != nil {
+		fail(c, apperror.New(apperror.PhotoNotFound).With("id", c.Param("photoId")))
+		return
+	}
 
-	err = db.Debug().Where("email = ?", email).First(&userHasLogin).Error
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"status":  "Error",
-			"message": "User with email " + email + " not found",
-			"data":    nil,
-		})
+	// Validate the user ID
+	if userHasLogin.ID != photo.UserID {
+		fail(c, apperror.New(apperror.PhotoForbidden))
 		return
 	}
+	if !preconditionMet(c, photo.Version) {
+		return
+	}
+	// Leaving out the visibility keeps the current one instead of making the photo public
+	if strings.TrimSpace(input.Visibility) == "" {
+		photoInput.Visibility = photo.Visibility
+	}
 
-	// Read the request body
-	body, err := ioutil.ReadAll(c.Request.Body)
-	if err != nil {
-		c.JSON(http.StatusUnprocessableEntity, gin.H{
-			"status":  "Error",
-			"message": err.Error(),
-			"data":    nil,
-		})
+	// Fetch the image into our storage when a source URL is given
+	if !ingestPhoto(c, &photoInput, userHasLogin.ID) {
+		return
 	}
 
-	// Convert JSON to an object
-	photoInput := models.Photo{}
-	err = json.Unmarshal(body, &photoInput)
-	if err != nil {
-		c.JSON(http.StatusUnprocessableEntity, gin.H{
-			"status":  "Error",
-			"message": err.Error(),
-			"data":    nil,
-		})
+	// Update the photo in the database
+	if !updatePhoto(c, db, &photo, &photoInput) {
 		return
 	}
 
-	// Validate the photo
-	err = photoInput.Validate("change")
-	if err != nil {
-		c.JSON(http.StatusUnprocessableEntity, gin.H{
-			"status":  "Error",
-			"message": err.Error(),
-			"data":    nil,
-		})
+	photo.Owner = app.Owner{
+		ID:       userHasLogin.ID,
+		Username: userHasLogin.Username,
+	}
+	photo.SignURL()
+	publish(c, events.Event{Type: events.PhotoUpdated, ActorID: userHasLogin.ID, UserID: userHasLogin.ID, PhotoID: photo.ID, Data: photo})
+
+	// Response for success
+	c.Header("ETag", entityTag(photo.Version, photo))
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "Success",
+		"message": translate(c, "PHOTO_UPDATED"),
+		"data":    photo,
+	})
+}
+
+// PatchPhoto applies a JSON Merge Patch to a photo of the logged in user.
+// Only the members sent are changed; sending tags replaces all of them.
+func PatchPhoto(c *gin.Context) {
+	// Set the database
+	db := c.MustGet("db").(*gorm.DB)
+
+	// Get the logged in user
+	userHasLogin, ok := currentUser(c, db)
+	if !ok {
 		return
 	}
 
 	// Check if the photo already exists
 	var photo models.Photo
-	if err := db.Debug().Where("id = ?", c.Param("photoId")).First(&photo).Error; err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"status":  "Error",
-			"message": "Photo with id " + c.Param("photoId") + " not found",
-			"data":    nil,
-		})
+	if err := db.Where("id = ?", c.Param("photoId")).First(&photo).Error; err != nil {
+		fail(c, apperror.New(apperror.PhotoNotFound).With("id", c.Param("photoId")))
 		return
 	}
 
 	// Validate the user ID
 	if userHasLogin.ID != photo.UserID {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"status":  "Error",
-			"message": "You can't change the photo of another user",
-			"data":    nil,
-		})
+		fail(c, apperror.New(apperror.PhotoForbidden))
+		return
+	}
+	if !preconditionMet(c, photo.Version) {
+		return
+	}
+	if err := applyPhotoTags(db, &photo); err != nil {
+		fail(c, err)
+		return
+	}
+
+	// The stored values are escaped, the patch is applied to what the user once sent
+	current := app.PhotoInput{
+		Title:      html.UnescapeString(photo.Title),
+		Caption:    html.UnescapeString(photo.Caption),
+		PhotoUrl:   html.UnescapeString(photo.PhotoUrl),
+		Visibility: photo.Visibility,
+		Tags:       photo.Tags,
+	}
+	var input app.PhotoInput
+	keys, ok := bindMergePatch(c, current, &input)
+	if !ok {
+		return
+	}
+	photoInput := photoFromInput(input)
+	photoInput.Init()
+
+	// Tags are only replaced when sent, null clears them
+	if !keys["tags"] {
+		photoInput.Tags = nil
+	} else if photoInput.Tags == nil {
+		photoInput.Tags = []string{}
+	}
+	if _, err := models.NormalizeTags(photoInput.Tags); err != nil {
+		fail(c, apperror.Invalid(err))
+		return
+	}
+
+	// The stored file stays unless a new URL was sent
+	if !keys["photo_url"] && !keys["source_url"] {
+		photoInput.FileKey = photo.FileKey
+	}
+	if !ingestPhoto(c, &photoInput, userHasLogin.ID) {
 		return
 	}
 
 	// Update the photo in the database
-	err = db.Model(&photo).Updates(&photoInput).Error
-	if err != nil {
-		formattedError := errorformat.ErrorMessage(err.Error())
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"status":  "Error",
-			"message": formattedError.Error(),
-			"data":    nil,
-		})
+	if !updatePhoto(c, db, &photo, &photoInput) {
 		return
 	}
 
 	photo.Owner = app.Owner{
 		ID:       userHasLogin.ID,
 		Username: userHasLogin.Username,
-		Email:    userHasLogin.Email,
 	}
+	photo.SignURL()
+	publish(c, events.Event{Type: events.PhotoUpdated, ActorID: userHasLogin.ID, UserID: userHasLogin.ID, PhotoID: photo.ID, Data: photo})
 
 	// Response for success
+	c.Header("ETag", entityTag(photo.Version, photo))
 	c.JSON(http.StatusOK, gin.H{
 		"status":  "Success",
-		"message": "Photo updated successfully",
+		"message": translate(c, "PHOTO_UPDATED"),
 		"data":    photo,
 	})
 }
@@ -306,69 +392,167 @@ func DeletePhoto(c *gin.Context) {
 	// Set the database
 	db := c.MustGet("db").(*gorm.DB)
 
-	// Get the bearer token
-	tokenString := c.GetHeader("Authorization")
-	if tokenString == "" {
-		c.JSON(401, gin.H{"error": "Token not found"})
-		return
-	}
-
-	// Get the user email from JWT
-	email, err := auth.GetEmail(strings.Split(tokenString, "Bearer ")[1])
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"status":  "Error",
-			"message": err.Error(),
-			"data":    nil,
-		})
-	}
-
-	// Get user data from JWT
-	var userHasLogin models.User
-	if err := db.Debug().Where("email = ?", email).First(&userHasLogin).Error; err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"status":  "Error",
-			"message": "User with email " + email + " not found",
-			"data":    nil,
-		})
+	// Get the logged in user
+	userHasLogin, ok := currentUser(c, db)
+	if !ok {
 		return
 	}
 
 	// Check if the photo already exists
 	var photo models.Photo
-	if err := db.Debug().Where("id = ?", c.Param("photoId")).First(&photo).Error; err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"status":  "Error",
-			"message": "Photo not found",
-			"data":    nil,
-		})
+	if err := db.Where("id = ?", c.Param("photoId")).First(&photo).Error; err != nil {
+		fail(c, apperror.New(apperror.PhotoNotFound).With("id", c.Param("photoId")))
 		return
 	}
 
 	// Validate the user ID
 	if userHasLogin.ID != photo.UserID {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"status":  "Error",
-			"message": "You can't delete the photo of another user",
-			"data":    nil,
-		})
+		fail(c, apperror.Keyed(apperror.PhotoForbidden, "PHOTO_FORBIDDEN_DELETE"))
+		return
+	}
+	if !preconditionMet(c, photo.Version) {
 		return
 	}
 
-	// Delete the photo from the database
-	err = db.Debug().Delete(&photo).Error
+	// Soft-delete the photo, its tags stop counting. The purge removes it for good after the retention period
+	err := inTransaction(db, func(tx *gorm.DB) error {
+		if err := countPhotoTags(tx, []int{photo.ID}, -1); err != nil {
+			return err
+		}
+		return checkVersioned(tx.Scopes(versioned(photo.Version)).Delete(&photo))
+	})
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"status":  "Error",
-			"message": err.Error(),
-			"data":    nil,
-		})
+		fail(c, err)
 		return
 	}
 
+	publish(c, events.Event{Type: events.PhotoDeleted, ActorID: userHasLogin.ID, UserID: userHasLogin.ID, PhotoID: photo.ID, Data: photo})
+
 	c.JSON(http.StatusOK, gin.H{
 		"status":  "Success",
-		"message": "Photo deleted successfully",
+		"message": translate(c, "PHOTO_DELETED"),
 		"data":    nil,
 	}) // Return the response
 }
+
+// ingestPhoto downloads photo.SourceURL into storage and points the photo at the stored file.
+// It writes the error response itself and returns false when the URL can't be ingested.
+func ingestPhoto(c *gin.Context, photo *models.Photo, userID string) bool {
+	if photo.SourceURL == "" {
+		return true
+	}
+
+	image, err := photoFetcher.Fetch(c.Request.Context(), photo.SourceURL)
+	if err != nil {
+		fail(c, apperror.New(apperror.PhotoSourceFailed).With("reason", err.Error()).Because(err))
+		return false
+	}
+
+	store := c.MustGet("storage").(storage.Storage)
+	key := "photos/" + userID + "/" + uuid.New().String() + image.Ext
+	if _, err := store.Put(key, bytes.NewReader(image.Data)); err != nil {
+		fail(c, err)
+		return false
+	}
+	metrics.UploadSize.Observe(float64(len(image.Data)))
+
+	photo.FileKey = key
+	photo.PhotoUrl = html.EscapeString(photo.SourceURL)
+	return true
+}
+
+// photoFromInput returns the photo described by a create or update request.
+func photoFromInput(input app.PhotoInput) models.Photo {
+	return models.Photo{
+		Title:      input.Title,
+		Caption:    input.Caption,
+		PhotoUrl:   input.PhotoUrl,
+		SourceURL:  input.SourceURL,
+		Visibility: input.Visibility,
+		Tags:       input.Tags,
+	}
+}
+
+// updatePhoto saves input over the stored photo in one transaction and removes the file it replaced once committed,
+// or the file fetched for input when the write fails. It reports the error itself and returns false when the photo wasn't saved.
+func updatePhoto(c *gin.Context, db *gorm.DB, stored *models.Photo, input *models.Photo) bool {
+	var saved, changes models.Photo
+	replaced := ""
+	err := inTransaction(db, func(tx *gorm.DB) error {
+		saved, changes = *stored, *input
+		var err error
+		replaced, err = savePhoto(tx, &saved, &changes)
+		return err
+	})
+	if err != nil {
+		// A file fetched for this write isn't used by any photo
+		if input.FileKey != stored.FileKey {
+			removeFile(c, input.FileKey)
+		}
+		fail(c, err)
+		return false
+	}
+	*stored, *input = saved, changes
+	removeFile(c, replaced)
+	return true
+}
+
+// savePhoto writes input over the stored photo and returns the key of the stored file it replaced, if any.
+// The caller removes that file once the transaction commits. A photo_url that is just a signed link to the current file keeps that file.
+func savePhoto(db *gorm.DB, stored *models.Photo, input *models.Photo) (string, error) {
+	fileKey := input.FileKey
+	if fileKey == "" && stored.FileKey != "" && strings.Contains(input.PhotoUrl, "/files/"+stored.FileKey) {
+		fileKey = stored.FileKey
+		input.PhotoUrl = stored.PhotoUrl // Don't keep the expiring signed URL
+	}
+
+	// Updates copies the new values into stored, so the replaced key is kept first
+	oldKey := stored.FileKey
+
+	// Ownership and counters are never taken from the request, and a write that raced another one is refused
+	input.Version = stored.Version + 1
+	result := db.Model(stored).Scopes(versioned(stored.Version)).Omit("user_id", "like_count", "created_at").Updates(input)
+	if err := checkVersioned(result); err != nil {
+		return "", err
+	}
+
+	// Replace the tags when they were sent
+	stored.Tags = input.Tags
+	if err := applyPhotoTags(db, stored); err != nil {
+		return "", err
+	}
+	input.Tags = stored.Tags
+
+	if fileKey == oldKey {
+		return "", nil
+	}
+
+	if err := db.Model(stored).Update("file_key", fileKey).Error; err != nil {
+		return "", err
+	}
+	input.FileKey = fileKey
+	return oldKey, nil
+}
+
+// removeFile deletes a stored file that no photo uses anymore.
+func removeFile(c *gin.Context, key string) {
+	if key != "" {
+		c.MustGet("storage").(storage.Storage).Delete(key)
+	}
+}
+
+// applyPhotoTags stores the tags sent for a saved photo, or loads its current tags when none were sent.
+func applyPhotoTags(db *gorm.DB, photo *models.Photo) error {
+	if photo.Tags != nil {
+		tags, err := setPhotoTags(db, photo.ID, photo.Tags)
+		photo.Tags = tags
+		return err
+	}
+
+	photos := []models.Photo{*photo}
+	if err := attachTags(db, photos); err != nil {
+		return err
+	}
+	photo.Tags = photos[0].Tags
+	return nil
+}
@@ -0,0 +1,247 @@
+package controllers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"testing"
+
+	"task-5-pbi-btpns-arthagusfiputra/controllers"
+	"task-5-pbi-btpns-arthagusfiputra/models"
+
+	"github.com/jinzhu/gorm"
+)
+
+// TestGetPhotoQueryCount checks that the photo list loads its owners in batches,
+// so a full page of 100 photos takes as many queries as a page of 10.
+func TestGetPhotoQueryCount(t *testing.T) {
+	queries := map[int]int64{}
+	for _, n := range []int{10, 1000} {
+		db := openDB(t)
+		seedUsers(t, db, n)
+		h := newHandler(db, http.MethodGet, "/photos", controllers.GetPhoto)
+
+		count := countQueries(db)
+		code, body := call(h, http.MethodGet, "/photos?limit=100", "", nil)
+		if code != http.StatusOK {
+			t.Fatalf("%d photos: got status %d: %v", n, code, body)
+		}
+
+		photos := body["data"].([]interface{})
+		if want := min(n, 100); len(photos) != want {
+			t.Fatalf("%d photos: got %d photos, want %d", n, len(photos), want)
+		}
+		for _, photo := range photos {
+			owner := photo.(map[string]interface{})["Owner"].(map[string]interface{})
+			if owner["id"] == "" {
+				t.Fatalf("%d photos: photo %v has no owner", n, photo)
+			}
+		}
+		queries[n] = *count
+	}
+
+	if queries[10] != queries[1000] {
+		t.Errorf("got %d queries for 10 photos and %d for 1000", queries[10], queries[1000])
+	}
+}
+
+// TestPhotoVisibility checks who sees each visibility level in the listings and through a direct link.
+// Unlisted photos are only reachable by link, followers-only photos only by followers, private ones only by the owner.
+func TestPhotoVisibility(t *testing.T) {
+	h, db := newServer(t)
+	alice, aliceToken := seedUser(t, db, "alice")
+	bob, bobToken := seedUser(t, db, "bob")
+	_, carolToken := seedUser(t, db, "carol")
+	if err := db.Create(&models.Follow{FollowerID: bob.ID, FolloweeID: alice.ID}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	ids := map[string]int{}
+	for _, visibility := range []string{models.VisibilityPublic, models.VisibilityUnlisted, models.VisibilityFollowers, models.VisibilityPrivate} {
+		photo := seedPhoto(t, db, alice)
+		if err := db.Model(&photo).UpdateColumn("visibility", visibility).Error; err != nil {
+			t.Fatal(err)
+		}
+		ids[visibility] = photo.ID
+	}
+
+	cases := []struct {
+		viewer string
+		token  string
+		listed []string
+		linked []string
+	}{
+		{"anonymous", "", []string{"public"}, []string{"public", "unlisted"}},
+		{"stranger", carolToken, []string{"public"}, []string{"public", "unlisted"}},
+		{"follower", bobToken, []string{"followers", "public"}, []string{"followers", "public", "unlisted"}},
+		{"owner", aliceToken, []string{"followers", "private", "public", "unlisted"}, []string{"followers", "private", "public", "unlisted"}},
+	}
+	for _, tc := range cases {
+		var listed, linked []string
+		for _, path := range []string{"/photos", "/users/" + alice.ID + "/photos"} {
+			code, body := call(h, http.MethodGet, path, tc.token, nil)
+			if code != http.StatusOK {
+				t.Fatalf("%s %s: got status %d: %v", tc.viewer, path, code, body)
+			}
+			var got []string
+			for _, photo := range body["data"].([]interface{}) {
+				got = append(got, photo.(map[string]interface{})["visibility"].(string))
+			}
+			sort.Strings(got)
+			if listed == nil {
+				listed = got
+			} else if !slices.Equal(got, listed) {
+				t.Errorf("%s: %s lists %v, /photos lists %v", tc.viewer, path, got, listed)
+			}
+		}
+		for visibility, id := range ids {
+			code, _ := call(h, http.MethodGet, "/photos/"+strconv.Itoa(id), tc.token, nil)
+			if code == http.StatusOK {
+				linked = append(linked, visibility)
+			} else if code != http.StatusNotFound {
+				t.Errorf("%s, %s photo: got status %d", tc.viewer, visibility, code)
+			}
+		}
+		sort.Strings(linked)
+
+		if !slices.Equal(listed, tc.listed) {
+			t.Errorf("%s: listings show %v, want %v", tc.viewer, listed, tc.listed)
+		}
+		if !slices.Equal(linked, tc.linked) {
+			t.Errorf("%s: links open %v, want %v", tc.viewer, linked, tc.linked)
+		}
+	}
+}
+
+// TestUpdatePhotoKeepsVisibility checks that a PUT leaving out the visibility doesn't publish a private photo.
+func TestUpdatePhotoKeepsVisibility(t *testing.T) {
+	h, db := newServer(t)
+	alice, aliceToken := seedUser(t, db, "alice")
+	photo := seedPhoto(t, db, alice)
+	if err := db.Model(&photo).UpdateColumn("visibility", models.VisibilityPrivate).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	update := map[string]string{"title": "New title", "caption": "caption", "photo_url": "https://example.com/new.jpg"}
+	if code, body := call(h, http.MethodPut, "/photos/"+strconv.Itoa(photo.ID), aliceToken, update); code != http.StatusOK {
+		t.Fatalf("got status %d: %v", code, body)
+	}
+	var stored models.Photo
+	if err := db.Where("id = ?", photo.ID).First(&stored).Error; err != nil {
+		t.Fatal(err)
+	}
+	if stored.Title != "New title" || stored.Visibility != models.VisibilityPrivate {
+		t.Errorf("got %q with visibility %q, want the new title and %q", stored.Title, stored.Visibility, models.VisibilityPrivate)
+	}
+}
+
+// TestIngestReplacesFile checks that each write fetching a new file removes the file it replaces,
+// whether it comes through PUT, PATCH or a new upload.
+func TestIngestReplacesFile(t *testing.T) {
+	h, db := newServer(t)
+	server := imageServer(t)
+	alice, token := seedUser(t, db, "alice")
+
+	upload := map[string]string{"title": "Title", "caption": "caption", "source_url": server.URL + "/1.png"}
+	code, body := call(h, http.MethodPost, "/photos", token, upload)
+	if code != http.StatusOK {
+		t.Fatalf("upload: got status %d: %v", code, body)
+	}
+	id := strconv.Itoa(int(body["data"].(map[string]interface{})["id"].(float64)))
+
+	writes := []struct {
+		method string
+		path   string
+		body   interface{}
+	}{
+		{http.MethodPut, "/photos/" + id, map[string]string{"title": "Title", "caption": "caption", "source_url": server.URL + "/2.png"}},
+		{http.MethodPatch, "/photos/" + id, map[string]string{"source_url": server.URL + "/3.png"}},
+		{http.MethodPost, "/photos", map[string]string{"title": "Title", "caption": "caption", "source_url": server.URL + "/4.png"}},
+	}
+	for _, write := range writes {
+		before := storedFiles(t, alice.ID)
+		if code, body := call(h, write.method, write.path, token, write.body); code != http.StatusOK {
+			t.Fatalf("%s %s: got status %d: %v", write.method, write.path, code, body)
+		}
+
+		var photo models.Photo
+		if err := db.Where("user_id = ?", alice.ID).First(&photo).Error; err != nil {
+			t.Fatal(err)
+		}
+		after := storedFiles(t, alice.ID)
+		if len(before) != 1 || len(after) != 1 || after[0] != photo.FileKey || after[0] == before[0] {
+			t.Errorf("%s %s: stored files went from %v to %v, want only the new file %s", write.method, write.path, before, after, photo.FileKey)
+		}
+	}
+}
+
+// TestIngestFailedWriteRemovesFile checks that a file fetched for a write that fails isn't left in storage.
+func TestIngestFailedWriteRemovesFile(t *testing.T) {
+	h, db := newServer(t)
+	server := imageServer(t)
+	alice, token := seedUser(t, db, "alice")
+	photo := seedPhoto(t, db, alice)
+
+	// Another write bumps the version between the precondition check and the update
+	db.Callback().Update().Before("gorm:update").Register("test:race", func(scope *gorm.Scope) {
+		if scope.TableName() == "photos" {
+			scope.NewDB().Exec("UPDATE photos SET version = version + 1 WHERE id = ?", photo.ID)
+		}
+	})
+	defer db.Callback().Update().Remove("test:race")
+
+	update := map[string]string{"title": "Title", "caption": "caption", "source_url": server.URL + "/new.png"}
+	if code, body := call(h, http.MethodPut, "/photos/"+strconv.Itoa(photo.ID), token, update); code != http.StatusPreconditionFailed {
+		t.Fatalf("got status %d, want %d: %v", code, http.StatusPreconditionFailed, body)
+	}
+	if files := storedFiles(t, alice.ID); len(files) != 0 {
+		t.Errorf("got stored files %v, want none", files)
+	}
+}
+
+// TestPatchPhotoTags checks that a merge patch only replaces the tags when it names them, and that null clears them.
+func TestPatchPhotoTags(t *testing.T) {
+	h, db := newServer(t)
+	alice, aliceToken := seedUser(t, db, "alice")
+	photo := seedPhoto(t, db, alice)
+	path := "/photos/" + strconv.Itoa(photo.ID)
+
+	steps := []struct {
+		name  string
+		patch interface{}
+		title string
+		tags  []string
+	}{
+		{"tags are set", map[string]interface{}{"tags": []string{"sun", "sea"}}, photo.Title, []string{"sea", "sun"}},
+		{"tags are absent", map[string]interface{}{"title": "New title"}, "New title", []string{"sea", "sun"}},
+		{"tags are replaced", map[string]interface{}{"tags": []string{"sky"}}, "New title", []string{"sky"}},
+		{"tags are null", map[string]interface{}{"tags": nil}, "New title", []string{}},
+		{"tags are set again", map[string]interface{}{"tags": []string{"sky"}}, "New title", []string{"sky"}},
+		{"tags are empty", map[string]interface{}{"tags": []string{}}, "New title", []string{}},
+	}
+	for _, step := range steps {
+		if code, body := call(h, http.MethodPatch, path, aliceToken, step.patch); code != http.StatusOK {
+			t.Fatalf("%s: got status %d: %v", step.name, code, body)
+		}
+		code, body := call(h, http.MethodGet, path, "", nil)
+		if code != http.StatusOK {
+			t.Fatalf("%s: got status %d: %v", step.name, code, body)
+		}
+		data := body["data"].(map[string]interface{})
+		tags := []string{}
+		for _, tag := range data["tags"].([]interface{}) {
+			tags = append(tags, tag.(string))
+		}
+		sort.Strings(tags)
+		if data["title"] != step.title || !slices.Equal(tags, step.tags) {
+			t.Errorf("%s: got %q with tags %v, want %q with %v", step.name, data["title"], tags, step.title, step.tags)
+		}
+	}
+
+	// A patch must be an object, which null is not
+	if code, body := call(h, http.MethodPatch, path, aliceToken, json.RawMessage("null")); code != http.StatusBadRequest {
+		t.Errorf("null patch: got status %d: %v", code, body)
+	}
+}
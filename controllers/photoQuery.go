@@ -0,0 +1,222 @@
+package controllers
+
+import (
+	"strings"
+	"time"
+
+	"task-5-pbi-btpns-arthagusfiputra/helpers/apperror"
+	"task-5-pbi-btpns-arthagusfiputra/helpers/pagination"
+	"task-5-pbi-btpns-arthagusfiputra/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+)
+
+// photoQuery holds the paging, sorting and filter options of GET /photos.
+type photoQuery struct {
+	Limit   int
+	Sort    string
+	Desc    bool
+	Cursor  *pagination.Cursor
+	After   interface{}
+	UserID  string
+	Title   string
+	From    *time.Time
+	To      *time.Time
+	Tags    []string
+	AllTags bool
+}
+
+// parsePhotoQuery reads the photo list options from the query string.
+func parsePhotoQuery(c *gin.Context) (photoQuery, error) {
+	q := photoQuery{Sort: "created_at", Desc: true}
+
+	limit, err := pagination.ParseLimit(c.Query("limit"))
+	if err != nil {
+		return q, err
+	}
+	q.Limit = limit
+
+	switch c.DefaultQuery("sort", "created_at") {
+	case "created_at":
+	case "title":
+		q.Sort, q.Desc = "title", false
+	default:
+		return q, apperror.Field("sort", "FIELD_ONE_OF").With("values", "created_at, title")
+	}
+
+	switch strings.ToLower(c.Query("order")) {
+	case "":
+	case "asc":
+		q.Desc = false
+	case "desc":
+		q.Desc = true
+	default:
+		return q, apperror.Field("order", "FIELD_ONE_OF").With("values", "asc, desc")
+	}
+
+	if s := c.Query("cursor"); s != "" {
+		cursor, err := pagination.Decode(s)
+		if err != nil {
+			return q, err
+		}
+		if cursor.Sort != q.sortKey() {
+			return q, apperror.Field("cursor", "CURSOR_SORT_MISMATCH")
+		}
+		q.Cursor = &cursor
+		q.After = cursor.Value
+		if q.Sort == "created_at" {
+			t, err := time.Parse(time.RFC3339Nano, cursor.Value)
+			if err != nil {
+				return q, apperror.Field("cursor", "CURSOR_INVALID")
+			}
+			q.After = t
+		}
+	}
+
+	q.UserID = c.Query("user_id")
+	q.Title = strings.TrimSpace(c.Query("title"))
+
+	if s := c.Query("tag"); s != "" {
+		tags, err := models.NormalizeTags(strings.Split(s, ","))
+		if err != nil {
+			return q, err
+		}
+		q.Tags = tags
+	}
+	switch strings.ToLower(c.Query("tag_match")) {
+	case "", "any":
+	case "all":
+		q.AllTags = true
+	default:
+		return q, apperror.Field("tag_match", "FIELD_ONE_OF").With("values", "any, all")
+	}
+
+	if s := c.Query("from"); s != "" {
+		from, err := parseDate(s, false)
+		if err != nil {
+			return q, apperror.Field("from", "FIELD_DATE")
+		}
+		q.From = &from
+	}
+	if s := c.Query("to"); s != "" {
+		to, err := parseDate(s, true)
+		if err != nil {
+			return q, apperror.Field("to", "FIELD_DATE")
+		}
+		q.To = &to
+	}
+
+	return q, nil
+}
+
+// sortKey identifies the sort a cursor was issued for.
+func (q photoQuery) sortKey() string {
+	if q.Desc {
+		return q.Sort + ":desc"
+	}
+	return q.Sort + ":asc"
+}
+
+// apply adds the filters, keyset condition, ordering and limit to the query.
+func (q photoQuery) apply(db *gorm.DB) *gorm.DB {
+	if q.UserID != "" {
+		db = db.Where("photos.user_id = ?", q.UserID)
+	}
+	if q.Title != "" {
+		db = db.Where("photos.title LIKE ? ESCAPE ?", "%"+escapeLike(q.Title)+"%", likeEscape)
+	}
+	if len(q.Tags) > 0 {
+		tagged := db.New().Table("photo_tags").Select("photo_tags.photo_id").
+			Joins("JOIN tags ON tags.id = photo_tags.tag_id").
+			Where("tags.name IN (?)", q.Tags)
+		if q.AllTags {
+			tagged = tagged.Group("photo_tags.photo_id").Having("COUNT(DISTINCT photo_tags.tag_id) = ?", len(q.Tags))
+		}
+		db = db.Where("photos.id IN ?", tagged.SubQuery())
+	}
+	if q.From != nil {
+		db = db.Where("photos.created_at >= ?", *q.From)
+	}
+	if q.To != nil {
+		db = db.Where("photos.created_at < ?", *q.To)
+	}
+
+	op, dir := ">", "asc"
+	if q.Desc {
+		op, dir = "<", "desc"
+	}
+	column := "photos." + q.Sort
+
+	if q.Cursor != nil {
+		db = db.Where("("+column+" "+op+" ?) OR ("+column+" = ? AND photos.id "+op+" ?)", q.After, q.After, q.Cursor.ID)
+	}
+
+	// Fetch one extra row to know whether there is a next page
+	return db.Order(column + " " + dir).Order("photos.id " + dir).Limit(q.Limit + 1)
+}
+
+// page trims the extra row and returns the paging metadata.
+func (q photoQuery) page(photos *[]models.Photo) pagination.Paging {
+	paging := pagination.Paging{Limit: q.Limit}
+	if len(*photos) <= q.Limit {
+		return paging
+	}
+
+	*photos = (*photos)[:q.Limit]
+	last := (*photos)[q.Limit-1]
+	cursor := pagination.Cursor{Sort: q.sortKey(), ID: last.ID, Value: last.Title}
+	if q.Sort == "created_at" {
+		cursor.Value = last.CreatedAt.Format(time.RFC3339Nano)
+	}
+	paging.HasMore = true
+	paging.NextCursor = cursor.Encode()
+	return paging
+}
+
+// visiblePhotos limits a photo query to what viewerID may see (empty for anonymous callers).
+// Unlisted photos are only reachable by direct link, so lists leave them out.
+// Followers-only photos are shown to their owner and the users following the owner. Deleted photos are never visible.
+func visiblePhotos(viewerID string, direct bool) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		db = db.Where("photos.deleted_at IS NULL")
+		shared := []string{models.VisibilityPublic}
+		if direct {
+			shared = append(shared, models.VisibilityUnlisted)
+		}
+		if viewerID == "" {
+			return db.Where("photos.visibility IN (?)", shared)
+		}
+		followed := db.New().Model(&models.Follow{}).Select("followee_id").Where("follower_id = ?", viewerID).SubQuery()
+		return db.Where("photos.visibility IN (?) OR photos.user_id = ? OR (photos.visibility = ? AND photos.user_id IN ?)",
+			shared, viewerID, models.VisibilityFollowers, followed)
+	}
+}
+
+// parseDate parses a YYYY-MM-DD date or an RFC3339 time.
+// Upper bounds are returned exclusive, so a plain date covers the whole day.
+func parseDate(s string, upper bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		if upper {
+			t = t.Add(time.Nanosecond)
+		}
+		return t, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", s, time.Local)
+	if err != nil {
+		return t, err
+	}
+	if upper {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
+
+// likeEscape is the escape character used by escapeLike. It is passed as the ESCAPE parameter of each LIKE,
+// since only MySQL treats a backslash as the escape character by default.
+const likeEscape = `\`
+
+// escapeLike escapes the LIKE wildcards in s. The LIKE using it needs ESCAPE likeEscape.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
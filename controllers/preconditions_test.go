@@ -0,0 +1,153 @@
+package controllers_test
+
+import (
+	"net/http"
+	"regexp"
+	"strconv"
+	"testing"
+
+	"github.com/jinzhu/gorm"
+)
+
+// TestPhotoETag checks the format of the ETag and the answers to If-None-Match.
+func TestPhotoETag(t *testing.T) {
+	h, db := newServer(t)
+	alice, _ := seedUser(t, db, "alice")
+	_, bobToken := seedUser(t, db, "bob")
+	photo := seedPhoto(t, db, alice)
+	path := "/photos/" + strconv.Itoa(photo.ID)
+
+	code, header, body := callWithHeader(h, http.MethodGet, path, "", nil, nil)
+	if code != http.StatusOK {
+		t.Fatalf("got status %d: %v", code, body)
+	}
+	etag := header.Get("ETag")
+	if !regexp.MustCompile(`^"1-[0-9a-f]{16}"$`).MatchString(etag) {
+		t.Fatalf("got ETag %s, want the version and a digest", etag)
+	}
+
+	tests := []struct {
+		name        string
+		ifNoneMatch string
+		want        int
+	}{
+		{"same tag", etag, http.StatusNotModified},
+		{"weak same tag", "W/" + etag, http.StatusNotModified},
+		{"one of several tags", `"9-0000000000000000", ` + etag, http.StatusNotModified},
+		{"any tag", "*", http.StatusNotModified},
+		{"other tag", `"1-0000000000000000"`, http.StatusOK},
+	}
+	for _, tt := range tests {
+		code, header, _ := callWithHeader(h, http.MethodGet, path, "", http.Header{"If-None-Match": {tt.ifNoneMatch}}, nil)
+		if code != tt.want {
+			t.Errorf("%s: got status %d, want %d", tt.name, code, tt.want)
+		}
+		if header.Get("ETag") != etag {
+			t.Errorf("%s: got ETag %s, want %s", tt.name, header.Get("ETag"), etag)
+		}
+	}
+
+	// A like doesn't change the version, but the cached representation is stale all the same
+	if code, body := call(h, http.MethodPost, path+"/like", bobToken, nil); code != http.StatusOK {
+		t.Fatalf("like: got status %d: %v", code, body)
+	}
+	code, header, _ = callWithHeader(h, http.MethodGet, path, "", http.Header{"If-None-Match": {etag}}, nil)
+	if code != http.StatusOK || header.Get("ETag") == etag || header.Get("ETag")[:3] != `"1-` {
+		t.Errorf("after a like: got status %d with ETag %s, want 200 with a new digest for version 1", code, header.Get("ETag"))
+	}
+}
+
+// TestPhotoIfMatch checks that writes only go ahead on the version the client has seen.
+func TestPhotoIfMatch(t *testing.T) {
+	h, db := newServer(t)
+	alice, token := seedUser(t, db, "alice")
+	photo := seedPhoto(t, db, alice)
+	path := "/photos/" + strconv.Itoa(photo.ID)
+	_, header, _ := callWithHeader(h, http.MethodGet, path, "", nil, nil)
+	first := header.Get("ETag")
+
+	patch := func(ifMatch string, title string) (int, string) {
+		var header http.Header
+		if ifMatch != "" {
+			header = http.Header{"If-Match": {ifMatch}}
+		}
+		code, response, _ := callWithHeader(h, http.MethodPatch, path, token, header, map[string]string{"title": title})
+		return code, response.Get("ETag")
+	}
+
+	code, second := patch(first, "Second")
+	if code != http.StatusOK || second[:3] != `"2-` {
+		t.Fatalf("current tag: got status %d with ETag %s, want 200 with version 2", code, second)
+	}
+	if code, _ := patch(first, "Stale"); code != http.StatusPreconditionFailed {
+		t.Errorf("stale tag: got status %d, want %d", code, http.StatusPreconditionFailed)
+	}
+	if code, _ := patch(`"1-0000000000000000", `+second, "Third"); code != http.StatusOK {
+		t.Errorf("one of several tags: got status %d, want %d", code, http.StatusOK)
+	}
+	if code, _ := patch("*", "Fourth"); code != http.StatusOK {
+		t.Errorf("any tag: got status %d, want %d", code, http.StatusOK)
+	}
+	if code, _ := patch("", "Fifth"); code != http.StatusOK {
+		t.Errorf("no tag: got status %d, want %d", code, http.StatusOK)
+	}
+
+	t.Setenv("REQUIRE_IF_MATCH", "true")
+	if code, _ := patch("", "Sixth"); code != http.StatusPreconditionRequired {
+		t.Errorf("no tag when required: got status %d, want %d", code, http.StatusPreconditionRequired)
+	}
+
+	var title string
+	if err := db.Table("photos").Where("id = ?", photo.ID).Select("title").Row().Scan(&title); err != nil {
+		t.Fatal(err)
+	}
+	if title != "Fifth" {
+		t.Errorf("got title %q, want %q", title, "Fifth")
+	}
+}
+
+// TestPhotoWriteLosesRace checks that a write whose precondition held when it was checked
+// still fails when another write changes the photo before it is saved.
+func TestPhotoWriteLosesRace(t *testing.T) {
+	h, db := newServer(t)
+	alice, token := seedUser(t, db, "alice")
+	photo := seedPhoto(t, db, alice)
+	path := "/photos/" + strconv.Itoa(photo.ID)
+	_, header, _ := callWithHeader(h, http.MethodGet, path, "", nil, nil)
+
+	// Another write bumps the version between the precondition check and the update
+	db.Callback().Update().Before("gorm:update").Register("test:race", func(scope *gorm.Scope) {
+		if scope.TableName() == "photos" {
+			scope.NewDB().Exec("UPDATE photos SET version = version + 1 WHERE id = ?", photo.ID)
+		}
+	})
+	defer db.Callback().Update().Remove("test:race")
+
+	writes := []struct {
+		method string
+		body   map[string]string
+	}{
+		{http.MethodPatch, map[string]string{"title": "Mine"}},
+		{http.MethodPut, map[string]string{"title": "Mine", "caption": "caption", "photo_url": "https://example.com/mine.jpg"}},
+	}
+	for _, write := range writes {
+		for _, ifMatch := range []string{header.Get("ETag"), ""} {
+			var header http.Header
+			if ifMatch != "" {
+				header = http.Header{"If-Match": {ifMatch}}
+			}
+			code, _, body := callWithHeader(h, write.method, path, token, header, write.body)
+			if code != http.StatusPreconditionFailed {
+				t.Errorf("%s with If-Match %q: got status %d, want %d: %v", write.method, ifMatch, code, http.StatusPreconditionFailed, body)
+			}
+		}
+	}
+
+	var title string
+	if err := db.Table("photos").Where("id = ?", photo.ID).Select("title").Row().Scan(&title); err != nil {
+		t.Fatal(err)
+	}
+	if title != photo.Title {
+		t.Errorf("got title %q, want the unchanged %q", title, photo.Title)
+	}
+}
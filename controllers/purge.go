@@ -0,0 +1,119 @@
+package controllers
+
+import (
+	"log/slog"
+	"os"
+	"time"
+
+	"task-5-pbi-btpns-arthagusfiputra/app/storage"
+	"task-5-pbi-btpns-arthagusfiputra/models"
+
+	"github.com/jinzhu/gorm"
+)
+
+// DefaultRetention is how long deleted users and photos can be restored when DELETED_RETENTION is unset.
+const DefaultRetention = 30 * 24 * time.Hour
+
+// purgeBatch is how many photos or idempotency keys one purge step removes.
+const purgeBatch = 100
+
+// Retention returns the configured time deleted users and photos are kept before the purge removes them.
+func Retention() time.Duration {
+	if retention, err := time.ParseDuration(os.Getenv("DELETED_RETENTION")); err == nil && retention > 0 {
+		return retention
+	}
+	return DefaultRetention
+}
+
+// SchedulePurge removes the users and photos deleted longer than retention ago and the expired idempotency keys,
+// checking every interval. It runs in its own goroutine for the lifetime of the server.
+func SchedulePurge(db *gorm.DB, store storage.Storage, retention time.Duration, interval time.Duration) {
+	for {
+		users, photos, err := PurgeDeleted(db, store, time.Now().Add(-retention))
+		if err != nil {
+			slog.Error("purge", "error", err)
+		} else if users > 0 || photos > 0 {
+			slog.Info("purge", "users", users, "photos", photos)
+		}
+
+		keys, err := PurgeIdempotencyKeys(db, time.Now())
+		if err != nil {
+			slog.Error("purge: idempotency keys", "error", err)
+		} else if keys > 0 {
+			slog.Info("purge: idempotency keys", "keys", keys)
+		}
+		time.Sleep(interval)
+	}
+}
+
+// PurgeDeleted permanently removes the users and photos deleted before cutoff, and the stored files of the photos.
+// Everything else they own goes with them through the foreign keys. It returns how many users and photos were removed.
+func PurgeDeleted(db *gorm.DB, store storage.Storage, cutoff time.Time) (int, int, error) {
+	expiredUsers := db.New().Unscoped().Model(&models.User{}).Select("id").Where("deleted_at < ?", cutoff).SubQuery()
+
+	purgedPhotos := 0
+	for {
+		photos := []models.Photo{}
+		if err := db.Unscoped().Where("deleted_at < ? OR user_id IN ?", cutoff, expiredUsers).
+			Limit(purgeBatch).Find(&photos).Error; err != nil {
+			return 0, purgedPhotos, err
+		}
+		if len(photos) == 0 {
+			break
+		}
+
+		err := inTransaction(db, func(tx *gorm.DB) error {
+			for i := range photos {
+				if err := tx.Unscoped().Delete(&photos[i]).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return 0, purgedPhotos, err
+		}
+		purgedPhotos += len(photos)
+
+		// The files go once their rows are gone, so a failure only leaves an unused file behind
+		for _, photo := range photos {
+			if photo.FileKey == "" {
+				continue
+			}
+			if err := store.Delete(photo.FileKey); err != nil {
+				slog.Warn("purge: removing file", "key", photo.FileKey, "error", err)
+			}
+		}
+	}
+
+	users := []models.User{}
+	if err := db.Unscoped().Where("deleted_at < ?", cutoff).Find(&users).Error; err != nil {
+		return 0, purgedPhotos, err
+	}
+	for i := range users {
+		if err := db.Unscoped().Delete(&users[i]).Error; err != nil {
+			return i, purgedPhotos, err
+		}
+	}
+	return len(users), purgedPhotos, nil
+}
+
+// PurgeIdempotencyKeys removes the idempotency keys whose replay window is over at now, purgeBatch at a time.
+// Keys are otherwise only removed when a client sends the same one again. It returns how many were removed.
+func PurgeIdempotencyKeys(db *gorm.DB, now time.Time) (int, error) {
+	purged := 0
+	for {
+		ids := []int{}
+		if err := db.Model(&models.IdempotencyKey{}).Where("expires_at <= ?", now).Limit(purgeBatch).Pluck("id", &ids).Error; err != nil {
+			return purged, err
+		}
+		if len(ids) == 0 {
+			return purged, nil
+		}
+
+		if err := db.Where("id IN (?)", ids).Delete(&models.IdempotencyKey{}).Error; err != nil {
+			return purged, err
+		}
+		purged += len(ids)
+	}
+}
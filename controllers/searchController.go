@@ -0,0 +1,202 @@
+package controllers
+
+import (
+	"html"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"task-5-pbi-btpns-arthagusfiputra/app/search"
+	"task-5-pbi-btpns-arthagusfiputra/helpers/apperror"
+	"task-5-pbi-btpns-arthagusfiputra/helpers/pagination"
+	"task-5-pbi-btpns-arthagusfiputra/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+)
+
+// searchBatch is how many ranked hits of the in-memory index are checked for visibility with one query.
+const searchBatch = 1000
+
+// photoIndexLoad makes sure only one request loads the in-memory index.
+var photoIndexLoad sync.Mutex
+
+// searchResult is a photo with its relevance score and highlighted snippets.
+type searchResult struct {
+	models.Photo
+	Score      float64           `json:"score"`
+	Highlights map[string]string `json:"highlights"`
+}
+
+// SearchPhotos runs a full-text search over photo titles and captions, best match first.
+func SearchPhotos(c *gin.Context) {
+	// Set the database
+	db := c.MustGet("db").(*gorm.DB)
+
+	q := strings.TrimSpace(c.Query("q"))
+	if len(search.Tokenize(q)) == 0 {
+		fail(c, apperror.Field("q", "FIELD_REQUIRED"))
+		return
+	}
+	limit, err := pagination.ParseLimit(c.Query("limit"))
+	if err != nil {
+		fail(c, apperror.Invalid(err))
+		return
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		fail(c, apperror.Field("offset", "FIELD_NOT_NEGATIVE"))
+		return
+	}
+
+	callerID := viewerID(c, db)
+	visible := db.Model(&models.Photo{}).Scopes(visiblePhotos(callerID, false))
+
+	var photos []models.Photo
+	var scores []float64
+	if db.Dialect().GetName() == "mysql" {
+		photos, scores, err = searchNative(visible, q, limit+1, offset)
+	} else {
+		photos, scores, err = searchIndex(db, visible, q, limit+1, offset)
+	}
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	hasMore := len(photos) > limit
+	if hasMore {
+		photos, scores = photos[:limit], scores[:limit]
+	}
+
+	err = attachOwners(db, photos)
+	if err == nil {
+		err = presentPhotos(db, photos, callerID)
+	}
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	results := make([]searchResult, len(photos))
+	for i, photo := range photos {
+		results[i] = searchResult{
+			Photo: photo,
+			Score: scores[i],
+			Highlights: map[string]string{
+				"title":   search.Highlight(html.UnescapeString(photo.Title), q, 0),
+				"caption": search.Highlight(html.UnescapeString(photo.Caption), q, 160),
+			},
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "Success",
+		"message": translate(c, "DATA_RETRIEVED"),
+		"data":    results,
+		"paging": gin.H{
+			"limit":    limit,
+			"offset":   offset,
+			"has_more": hasMore,
+		},
+	})
+}
+
+// searchNative ranks photos with the MySQL FULLTEXT index on title and caption.
+func searchNative(visible *gorm.DB, q string, limit int, offset int) ([]models.Photo, []float64, error) {
+	type rankedPhoto struct {
+		models.Photo
+		Score float64
+	}
+
+	match := "MATCH (photos.title, photos.caption) AGAINST (? IN NATURAL LANGUAGE MODE)"
+	rows := []rankedPhoto{}
+	err := visible.Select("photos.*, "+match+" AS score", q).
+		Where(match, q).Order("score desc").Order("photos.id desc").
+		Limit(limit).Offset(offset).Scan(&rows).Error
+	if err != nil {
+		return nil, nil, err
+	}
+
+	photos := make([]models.Photo, len(rows))
+	scores := make([]float64, len(rows))
+	for i, row := range rows {
+		photos[i], scores[i] = row.Photo, row.Score
+	}
+	return photos, scores, nil
+}
+
+// searchIndex ranks photos with the in-memory index, loading it on first use, then walks the hits
+// a batch at a time and keeps those the caller may see in ranking order, until the page is full.
+func searchIndex(db *gorm.DB, visible *gorm.DB, q string, limit int, offset int) ([]models.Photo, []float64, error) {
+	if err := loadPhotoIndex(db); err != nil {
+		return nil, nil, err
+	}
+
+	hits := models.PhotoIndex.Search(q, 0)
+	photos := []models.Photo{}
+	scores := []float64{}
+	for start := 0; start < len(hits) && len(photos) < limit; start += searchBatch {
+		batch := hits[start:min(start+searchBatch, len(hits))]
+		ids := make([]int, len(batch))
+		for i, hit := range batch {
+			ids[i] = hit.ID
+		}
+
+		found := []models.Photo{}
+		if err := visible.Where("photos.id IN (?)", ids).Find(&found).Error; err != nil {
+			return nil, nil, err
+		}
+		byID := make(map[int]models.Photo, len(found))
+		for _, photo := range found {
+			byID[photo.ID] = photo
+		}
+
+		for _, hit := range batch {
+			photo, ok := byID[hit.ID]
+			if !ok {
+				continue
+			}
+			if offset > 0 {
+				offset--
+				continue
+			}
+			photos = append(photos, photo)
+			scores = append(scores, hit.Score)
+			if len(photos) == limit {
+				break
+			}
+		}
+	}
+	return photos, scores, nil
+}
+
+// loadPhotoIndex fills the in-memory index from the database once.
+// Afterwards the Photo save and delete hooks keep it up to date.
+func loadPhotoIndex(db *gorm.DB) error {
+	photoIndexLoad.Lock()
+	defer photoIndexLoad.Unlock()
+	if models.PhotoIndex.Ready() {
+		return nil
+	}
+
+	rows, err := db.Model(&models.Photo{}).Select("id, title, caption").Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int
+		var title, caption string
+		if err := rows.Scan(&id, &title, &caption); err != nil {
+			return err
+		}
+		models.PhotoIndex.Add(id, html.UnescapeString(title), html.UnescapeString(caption))
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	models.PhotoIndex.MarkReady()
+	return nil
+}
@@ -0,0 +1,122 @@
+package controllers_test
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"task-5-pbi-btpns-arthagusfiputra/models"
+
+	"github.com/jinzhu/gorm"
+)
+
+// searchTitles runs a photo search and returns the titles and highlighted titles of the results.
+func searchTitles(t *testing.T, h http.Handler, query string, token string) ([]string, []string) {
+	t.Helper()
+	code, body := call(h, http.MethodGet, "/search/photos?"+query, token, nil)
+	if code != http.StatusOK {
+		t.Fatalf("%s: got status %d: %v", query, code, body)
+	}
+	var titles, highlights []string
+	for _, result := range body["data"].([]interface{}) {
+		result := result.(map[string]interface{})
+		titles = append(titles, result["title"].(string))
+		highlights = append(highlights, result["highlights"].(map[string]interface{})["title"].(string))
+	}
+	return titles, highlights
+}
+
+// seedTitled inserts a photo of user with the title and visibility.
+func seedTitled(t *testing.T, db *gorm.DB, user models.User, title string, visibility string) models.Photo {
+	t.Helper()
+	photo := seedPhoto(t, db, user)
+	if err := db.Model(&photo).Updates(map[string]interface{}{"title": title, "visibility": visibility}).Error; err != nil {
+		t.Fatal(err)
+	}
+	return photo
+}
+
+// TestSearchPhotos checks the ranking, the highlights and the visibility of search results.
+func TestSearchPhotos(t *testing.T) {
+	h, db := newServer(t)
+	freshSearchIndex(t)
+	alice, aliceToken := seedUser(t, db, "alice")
+	seedTitled(t, db, alice, "Sunset over the harbour", models.VisibilityPublic)
+	seedTitled(t, db, alice, "Harbour, harbour", models.VisibilityPublic)
+	seedTitled(t, db, alice, "A long walk along the quiet harbour at dusk", models.VisibilityPublic)
+	seedTitled(t, db, alice, "Private harbour", models.VisibilityPrivate)
+	seedTitled(t, db, alice, "Mountains", models.VisibilityPublic)
+
+	titles, highlights := searchTitles(t, h, "q=harbour", "")
+	want := []string{"Harbour, harbour", "Sunset over the harbour", "A long walk along the quiet harbour at dusk"}
+	if strings.Join(titles, "|") != strings.Join(want, "|") {
+		t.Errorf("anonymous: got %q, want %q", titles, want)
+	}
+	if len(highlights) > 0 && highlights[0] != "<mark>Harbour</mark>, <mark>harbour</mark>" {
+		t.Errorf("got highlight %q", highlights[0])
+	}
+
+	if titles, _ := searchTitles(t, h, "q=harbour", aliceToken); len(titles) != 4 {
+		t.Errorf("owner: got %q, want the private photo too", titles)
+	}
+	if titles, _ := searchTitles(t, h, "q=harbour&limit=1&offset=1", ""); len(titles) != 1 || titles[0] != want[1] {
+		t.Errorf("second page: got %q, want %q", titles, want[1:2])
+	}
+}
+
+// TestSearchPastFirstBatch checks that photos the caller may see are found and paged
+// even when more than a batch of better ranked hits are hidden from them.
+func TestSearchPastFirstBatch(t *testing.T) {
+	h, db := newServer(t)
+	freshSearchIndex(t)
+	alice, aliceToken := seedUser(t, db, "alice")
+
+	const hidden = 1001
+	err := db.Exec(`WITH RECURSIVE seq(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM seq WHERE i < ?)
+		INSERT INTO photos (title, caption, photo_url, user_id, visibility, version, like_count, created_at, updated_at)
+		SELECT 'Harbour harbour', 'caption', 'https://example.com/' || i || '.jpg', ?, 'private', 1, 0, datetime('now'), datetime('now')
+		FROM seq`, hidden, alice.ID).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	seedTitled(t, db, alice, "A long walk along the quiet harbour at dusk", models.VisibilityPublic)
+
+	if titles, _ := searchTitles(t, h, "q=harbour", ""); len(titles) != 1 || !strings.HasPrefix(titles[0], "A long walk") {
+		t.Errorf("anonymous: got %q, want the public photo", titles)
+	}
+	titles, _ := searchTitles(t, h, "q=harbour&limit=5&offset="+strconv.Itoa(hidden-1), aliceToken)
+	if len(titles) != 2 || !strings.HasPrefix(titles[1], "A long walk") {
+		t.Errorf("owner, last page: got %q, want the last private photo and the public one", titles)
+	}
+}
+
+// TestSearchIndexRollback checks that a photo write that rolls back leaves the in-memory index as it was.
+func TestSearchIndexRollback(t *testing.T) {
+	h, db := newServer(t)
+	freshSearchIndex(t)
+	alice, token := seedUser(t, db, "alice")
+	photo := seedTitled(t, db, alice, "Sunset over the harbour", models.VisibilityPublic)
+	if titles, _ := searchTitles(t, h, "q=harbour", ""); len(titles) != 1 {
+		t.Fatalf("got %q, want the photo", titles)
+	}
+
+	// Another write bumps the version between the precondition check and the update
+	db.Callback().Update().Before("gorm:update").Register("test:race", func(scope *gorm.Scope) {
+		if scope.TableName() == "photos" {
+			scope.NewDB().Exec("UPDATE photos SET version = version + 1 WHERE id = ?", photo.ID)
+		}
+	})
+	defer db.Callback().Update().Remove("test:race")
+
+	update := map[string]string{"title": "Zebra crossing", "caption": "caption", "photo_url": "https://example.com/zebra.jpg"}
+	if code, body := call(h, http.MethodPut, "/photos/"+strconv.Itoa(photo.ID), token, update); code != http.StatusPreconditionFailed {
+		t.Fatalf("got status %d, want %d: %v", code, http.StatusPreconditionFailed, body)
+	}
+	if hits := models.PhotoIndex.Search("zebra", 10); len(hits) != 0 {
+		t.Errorf("the rolled back title was indexed: %v", hits)
+	}
+	if hits := models.PhotoIndex.Search("harbour", 10); len(hits) != 1 || hits[0].ID != photo.ID {
+		t.Errorf("the stored title is no longer indexed: %v", hits)
+	}
+}
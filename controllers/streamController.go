@@ -0,0 +1,133 @@
+package controllers
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"task-5-pbi-btpns-arthagusfiputra/app/events"
+	"task-5-pbi-btpns-arthagusfiputra/app/stream"
+	"task-5-pbi-btpns-arthagusfiputra/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+)
+
+// StreamEvents pushes photo and notification events to the logged in user as Server-Sent Events.
+// A client reconnecting with Last-Event-ID first receives the events it missed.
+func StreamEvents(c *gin.Context) {
+	// Set the database
+	db := c.MustGet("db").(*gorm.DB)
+
+	userHasLogin, ok := currentUser(c, db)
+	if !ok {
+		return
+	}
+	hub := c.MustGet("stream").(*stream.Hub)
+
+	// Browsers resend the header on reconnect, other clients may use the query string
+	lastEventID := c.GetHeader("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = c.Query("last_event_id")
+	}
+	subscriber, replay := hub.Subscribe(userHasLogin.ID, lastEventID)
+	defer hub.Unsubscribe(subscriber)
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no") // Keep proxies from buffering the stream
+	c.Status(http.StatusOK)
+
+	if _, err := fmt.Fprint(c.Writer, "retry: 3000\n\n"); err != nil {
+		return
+	}
+	for _, msg := range replay {
+		if writeEvent(c, msg) != nil {
+			return
+		}
+	}
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(hub.Heartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+
+		case msg, ok := <-subscriber.C:
+			if !ok {
+				if subscriber.Evicted() {
+					fmt.Fprint(c.Writer, ": too slow, reconnect with Last-Event-ID\n\n")
+					c.Writer.Flush()
+				}
+				return
+			}
+			if writeEvent(c, msg) != nil {
+				return
+			}
+			c.Writer.Flush()
+
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(c.Writer, ": heartbeat\n\n"); err != nil {
+				return
+			}
+			c.Writer.Flush()
+		}
+	}
+}
+
+// ForwardEvents returns the event handler that passes photo and notification events on to the stream hub.
+// Photo events only reach the users who may see the photo.
+func ForwardEvents(db *gorm.DB, hub *stream.Hub) events.Handler {
+	return func(e events.Event) {
+		var err error
+		switch e.Type {
+		case events.PhotoCreated, events.PhotoUpdated, events.PhotoDeleted:
+			photo, ok := e.Data.(models.Photo)
+			if !ok {
+				return
+			}
+			var audience []string
+			if audience, err = photoAudience(db, photo); err != nil {
+				break
+			}
+			var data interface{} = photo
+			if e.Type == events.PhotoDeleted {
+				data = gin.H{"id": photo.ID}
+			}
+			err = hub.Publish(e.Type, data, audience...)
+
+		case events.NotificationRecorded:
+			err = hub.Publish("notification", e.Data, e.UserID)
+		}
+		if err != nil {
+			slog.Error("streaming event", "event", e.Type, "error", err)
+		}
+	}
+}
+
+// photoAudience returns the users who may see photo, or nil when everyone may.
+func photoAudience(db *gorm.DB, photo models.Photo) ([]string, error) {
+	switch photo.Visibility {
+	case models.VisibilityPublic:
+		return nil, nil
+	case models.VisibilityFollowers:
+		followers := []string{}
+		if err := db.Model(&models.Follow{}).Where("followee_id = ?", photo.UserID).Pluck("follower_id", &followers).Error; err != nil {
+			return nil, err
+		}
+		return append(followers, photo.UserID), nil
+	default:
+		return []string{photo.UserID}, nil
+	}
+}
+
+// writeEvent writes msg in the text/event-stream format.
+func writeEvent(c *gin.Context, msg stream.Message) error {
+	_, err := fmt.Fprintf(c.Writer, "id: %d\nevent: %s\ndata: %s\n\n", msg.ID, msg.Event, msg.Data)
+	return err
+}
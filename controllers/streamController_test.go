@@ -0,0 +1,65 @@
+package controllers_test
+
+import (
+	"fmt"
+	"testing"
+
+	"task-5-pbi-btpns-arthagusfiputra/app/events"
+	"task-5-pbi-btpns-arthagusfiputra/app/stream"
+	"task-5-pbi-btpns-arthagusfiputra/controllers"
+	"task-5-pbi-btpns-arthagusfiputra/models"
+)
+
+// TestForwardPhotoAudience checks that photo events only reach the users who may see the photo.
+func TestForwardPhotoAudience(t *testing.T) {
+	db := openDB(t)
+	alice, _ := seedUser(t, db, "alice")
+	follower, _ := seedUser(t, db, "follower")
+	stranger, _ := seedUser(t, db, "stranger")
+	if err := db.Create(&models.Follow{FollowerID: follower.ID, FolloweeID: alice.ID}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	hub := stream.NewHub(8, 8)
+	forward := controllers.ForwardEvents(db, hub)
+	subscribers := map[string]*stream.Subscriber{}
+	for _, user := range []models.User{alice, follower, stranger} {
+		s, _ := hub.Subscribe(user.ID, "")
+		subscribers[user.Username] = s
+		defer hub.Unsubscribe(s)
+	}
+
+	tests := []struct {
+		visibility string
+		want       map[string]bool
+	}{
+		{models.VisibilityPublic, map[string]bool{"alice": true, "follower": true, "stranger": true}},
+		{models.VisibilityFollowers, map[string]bool{"alice": true, "follower": true}},
+		{models.VisibilityUnlisted, map[string]bool{"alice": true}},
+		{models.VisibilityPrivate, map[string]bool{"alice": true}},
+	}
+	for _, tt := range tests {
+		photo := seedPhoto(t, db, alice)
+		photo.Visibility = tt.visibility
+		forward(events.Event{Type: events.PhotoUpdated, Data: photo})
+
+		for name, s := range subscribers {
+			var got bool
+			select {
+			case msg := <-s.C:
+				got = msg.Event == events.PhotoUpdated
+			default:
+			}
+			if got != tt.want[name] {
+				t.Errorf("%s photo: %s received the event: %v, want %v", tt.visibility, name, got, tt.want[name])
+			}
+		}
+	}
+
+	// A deleted photo only carries its id
+	photo := seedPhoto(t, db, alice)
+	forward(events.Event{Type: events.PhotoDeleted, Data: photo})
+	if msg := <-subscribers["stranger"].C; string(msg.Data) != fmt.Sprintf(`{"id":%d}`, photo.ID) {
+		t.Errorf("got deleted photo event %s, want only the id", msg.Data)
+	}
+}
@@ -0,0 +1,205 @@
+package controllers
+
+import (
+	"net/http"
+
+	"task-5-pbi-btpns-arthagusfiputra/helpers/apperror"
+	"task-5-pbi-btpns-arthagusfiputra/helpers/pagination"
+	"task-5-pbi-btpns-arthagusfiputra/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+)
+
+// Autocomplete suggestions are shorter than the other lists.
+const (
+	defaultTagLimit = 10
+	maxTagLimit     = 50
+)
+
+// GetTags autocompletes tag names by prefix, most used first.
+func GetTags(c *gin.Context) {
+	// Set the database
+	db := c.MustGet("db").(*gorm.DB)
+
+	limit := defaultTagLimit
+	if c.Query("limit") != "" {
+		var err error
+		if limit, err = pagination.ParseLimit(c.Query("limit")); err != nil {
+			fail(c, apperror.Invalid(err))
+			return
+		}
+		if limit > maxTagLimit {
+			limit = maxTagLimit
+		}
+	}
+
+	tags := []models.Tag{}
+	query := db.Where("usage_count > 0")
+	if prefix := models.NormalizeTag(c.Query("prefix")); prefix != "" {
+		query = query.Where("name LIKE ? ESCAPE ?", escapeLike(prefix)+"%", likeEscape)
+	}
+	if err := query.Order("usage_count desc").Order("name").Limit(limit).Find(&tags).Error; err != nil {
+		fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "Success",
+		"message": translate(c, "DATA_RETRIEVED"),
+		"data":    tags,
+	})
+}
+
+// setPhotoTags replaces the tags of a photo and keeps the usage counts in step.
+// It returns the normalized tag names.
+func setPhotoTags(db *gorm.DB, photoID int, names []string) ([]string, error) {
+	names, err := models.NormalizeTags(names)
+	if err != nil {
+		return nil, err
+	}
+
+	err = inTransaction(db, func(tx *gorm.DB) error {
+		current := []models.PhotoTag{}
+		if err := tx.Where("photo_id = ?", photoID).Find(&current).Error; err != nil {
+			return err
+		}
+
+		tags, err := findOrCreateTags(tx, names)
+		if err != nil {
+			return err
+		}
+
+		// Work out which tags are added and which are removed
+		wanted := make(map[int]bool, len(tags))
+		for _, tag := range tags {
+			wanted[tag.ID] = true
+		}
+		had := make(map[int]bool, len(current))
+		removed := []int{}
+		for _, pt := range current {
+			had[pt.TagID] = true
+			if !wanted[pt.TagID] {
+				removed = append(removed, pt.TagID)
+			}
+		}
+		added := []int{}
+		for _, tag := range tags {
+			if !had[tag.ID] {
+				added = append(added, tag.ID)
+			}
+		}
+
+		if len(removed) > 0 {
+			if err := tx.Where("photo_id = ? AND tag_id IN (?)", photoID, removed).Delete(&models.PhotoTag{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&models.Tag{}).Where("id IN (?)", removed).
+				UpdateColumn("usage_count", gorm.Expr("usage_count - 1")).Error; err != nil {
+				return err
+			}
+		}
+		for _, tagID := range added {
+			if err := tx.Create(&models.PhotoTag{PhotoID: photoID, TagID: tagID}).Error; err != nil {
+				return err
+			}
+		}
+		if len(added) > 0 {
+			return tx.Model(&models.Tag{}).Where("id IN (?)", added).
+				UpdateColumn("usage_count", gorm.Expr("usage_count + 1")).Error
+		}
+		return nil
+	})
+	return names, err
+}
+
+// findOrCreateTags returns the tags with the given normalized names, creating missing ones.
+func findOrCreateTags(tx *gorm.DB, names []string) ([]models.Tag, error) {
+	tags := []models.Tag{}
+	if len(names) == 0 {
+		return tags, nil
+	}
+	if err := tx.Where("name IN (?)", names).Find(&tags).Error; err != nil {
+		return nil, err
+	}
+
+	existing := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		existing[tag.Name] = true
+	}
+	for _, name := range names {
+		if existing[name] {
+			continue
+		}
+		tag := models.Tag{Name: name}
+		if err := tx.Create(&tag).Error; err != nil {
+			// Another request may have created the tag in the meantime
+			if err := tx.Where("name = ?", name).First(&tag).Error; err != nil {
+				return nil, err
+			}
+		}
+		tags = append(tags, tag)
+	}
+	return tags, nil
+}
+
+// countPhotoTags moves the usage counts of the tags on the given photos by delta for every attachment.
+// Deleting photos takes their tags out of the counts and restoring them puts them back.
+func countPhotoTags(db *gorm.DB, photoIDs []int, delta int) error {
+	if len(photoIDs) == 0 {
+		return nil
+	}
+
+	// One change per attachment, grouped by tag
+	type tagUse struct {
+		TagID int
+		Uses  int
+	}
+	uses := []tagUse{}
+	if err := db.Table("photo_tags").Select("tag_id, COUNT(*) AS uses").
+		Where("photo_id IN (?)", photoIDs).Group("tag_id").Scan(&uses).Error; err != nil {
+		return err
+	}
+	for _, use := range uses {
+		if err := db.Model(&models.Tag{}).Where("id = ?", use.TagID).
+			UpdateColumn("usage_count", gorm.Expr("usage_count + ?", use.Uses*delta)).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// attachTags fills the Tags of every photo using a single query.
+func attachTags(db *gorm.DB, photos []models.Photo) error {
+	if len(photos) == 0 {
+		return nil
+	}
+
+	ids := make([]int, len(photos))
+	for i := range photos {
+		ids[i] = photos[i].ID
+		photos[i].Tags = []string{}
+	}
+
+	type photoTagName struct {
+		PhotoID int
+		Name    string
+	}
+	rows := []photoTagName{}
+	if err := db.Table("photo_tags").Select("photo_tags.photo_id, tags.name").
+		Joins("JOIN tags ON tags.id = photo_tags.tag_id").
+		Where("photo_tags.photo_id IN (?)", ids).Order("tags.name").Scan(&rows).Error; err != nil {
+		return err
+	}
+
+	index := make(map[int]int, len(photos))
+	for i := range photos {
+		index[photos[i].ID] = i
+	}
+	for _, row := range rows {
+		if i, ok := index[row.PhotoID]; ok {
+			photos[i].Tags = append(photos[i].Tags, row.Name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package controllers
+
+import (
+	"database/sql"
+
+	"task-5-pbi-btpns-arthagusfiputra/helpers/apperror"
+	"task-5-pbi-btpns-arthagusfiputra/models"
+
+	"github.com/jinzhu/gorm"
+)
+
+// maxAttempts is how often a unit of work runs when the database keeps aborting it to break a deadlock.
+const maxAttempts = 3
+
+// inTransaction runs work as one unit: everything it writes is committed together or not at all.
+// A unit started inside another one joins the outer transaction. A unit aborted by a deadlock or a lock wait
+// timeout is rolled back and run again, so work must start from scratch on every attempt.
+func inTransaction(db *gorm.DB, work func(tx *gorm.DB) error) error {
+	if _, ok := db.CommonDB().(*sql.Tx); ok {
+		return work(db)
+	}
+
+	var err error
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		// Work deferred with models.AfterCommit, such as search index updates, only runs when this attempt commits
+		tx, committed := models.OnCommit(db)
+		err = tx.Transaction(work)
+		if err == nil {
+			committed()
+		}
+		if !apperror.IsDeadlock(err) {
+			return err
+		}
+	}
+	return err
+}
+
+// forUpdate locks the rows a query reads until the transaction ends, so units of work on the same rows take turns.
+// Databases without row locks, such as SQLite, already let only one transaction write at a time.
+func forUpdate(db *gorm.DB) *gorm.DB {
+	switch db.Dialect().GetName() {
+	case "mysql", "postgres":
+		return db.Set("gorm:query_option", "FOR UPDATE")
+	}
+	return db
+}
+
+// lockUser loads a user and locks its row for the rest of the transaction.
+// Writes that depend on what else the user owns lock it first, so they can't interleave.
+func lockUser(tx *gorm.DB, id string) (models.User, error) {
+	var user models.User
+	err := tx.Scopes(forUpdate).Where("id = ?", id).First(&user).Error
+	return user, err
+}
@@ -0,0 +1,131 @@
+package controllers_test
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"testing"
+
+	"task-5-pbi-btpns-arthagusfiputra/models"
+)
+
+// parallel sends n requests at once and returns their statuses in the order they were made.
+func parallel(n int, send func(i int) int) []int {
+	codes := make([]int, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			codes[i] = send(i)
+		}(i)
+	}
+	wg.Wait()
+	return codes
+}
+
+// TestCreatePhotoConcurrent checks that parallel first uploads of one user leave a single photo:
+// the first one creates it and the others update it.
+func TestCreatePhotoConcurrent(t *testing.T) {
+	h, db := newServer(t)
+	user, token := seedUser(t, db, "alice")
+
+	codes := parallel(10, func(i int) int {
+		code, _ := call(h, http.MethodPost, "/photos", token, map[string]interface{}{
+			"title":     fmt.Sprintf("Upload %d", i),
+			"caption":   "caption",
+			"photo_url": "https://example.com/photo.jpg",
+		})
+		return code
+	})
+	for i, code := range codes {
+		if code != http.StatusOK {
+			t.Errorf("upload %d: got status %d", i, code)
+		}
+	}
+
+	var count int
+	if err := db.Model(&models.Photo{}).Where("user_id = ?", user.ID).Count(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Errorf("got %d photos, want 1", count)
+	}
+}
+
+// TestCreatePhotoConcurrentFiles checks that parallel first uploads by source_url keep only the file of the photo
+// that won: every file a later upload replaced, or fetched for a write that failed, is removed.
+func TestCreatePhotoConcurrentFiles(t *testing.T) {
+	h, db := newServer(t)
+	server := imageServer(t)
+	user, token := seedUser(t, db, "alice")
+
+	codes := parallel(10, func(i int) int {
+		code, _ := call(h, http.MethodPost, "/photos", token, map[string]interface{}{
+			"title":      fmt.Sprintf("Upload %d", i),
+			"caption":    "caption",
+			"source_url": fmt.Sprintf("%s/photo%d.png", server.URL, i),
+		})
+		return code
+	})
+	for i, code := range codes {
+		if code != http.StatusOK {
+			t.Errorf("upload %d: got status %d", i, code)
+		}
+	}
+
+	var photos []models.Photo
+	if err := db.Where("user_id = ?", user.ID).Find(&photos).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(photos) != 1 {
+		t.Fatalf("got %d photos, want 1", len(photos))
+	}
+	if files := storedFiles(t, user.ID); len(files) != 1 || files[0] != photos[0].FileKey {
+		t.Errorf("got stored files %v, want only %s", files, photos[0].FileKey)
+	}
+}
+
+// TestUpdateUserEmailConcurrent checks that two users taking the same email at once can't both get it.
+func TestUpdateUserEmailConcurrent(t *testing.T) {
+	const email = "shared@example.com"
+	cases := []struct {
+		method string
+		body   func(user models.User) interface{}
+	}{
+		{http.MethodPut, func(user models.User) interface{} {
+			return map[string]string{"username": user.Username, "email": email, "password": "passw0rd1"}
+		}},
+		{http.MethodPatch, func(user models.User) interface{} {
+			return map[string]string{"email": email}
+		}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.method, func(t *testing.T) {
+			h, db := newServer(t)
+			alice, aliceToken := seedUser(t, db, "alice")
+			bob, bobToken := seedUser(t, db, "bob")
+			users := []models.User{alice, bob}
+			tokens := []string{aliceToken, bobToken}
+
+			codes := parallel(2, func(i int) int {
+				code, _ := call(h, tc.method, "/users/"+users[i].ID, tokens[i], tc.body(users[i]))
+				return code
+			})
+			sort.Ints(codes)
+			if codes[0] != http.StatusOK || codes[1] != http.StatusConflict {
+				t.Errorf("got statuses %v, want one %d and one %d", codes, http.StatusOK, http.StatusConflict)
+			}
+
+			var count int
+			if err := db.Model(&models.User{}).Where("email = ?", email).Count(&count).Error; err != nil {
+				t.Fatal(err)
+			}
+			if count != 1 {
+				t.Errorf("got %d users with the email, want 1", count)
+			}
+		})
+	}
+}
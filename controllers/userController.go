@@ -1,15 +1,15 @@
 package controllers
 
 import (
-	"encoding/json"
-	"io/ioutil"
-	"log"
+	"html"
 	"net/http"
+	"time"
 
 	"task-5-pbi-btpns-arthagusfiputra/app"
 	"task-5-pbi-btpns-arthagusfiputra/app/auth"
-	errorformat "task-5-pbi-btpns-arthagusfiputra/helpers/error"
+	"task-5-pbi-btpns-arthagusfiputra/helpers/apperror"
 	"task-5-pbi-btpns-arthagusfiputra/helpers/hash"
+	"task-5-pbi-btpns-arthagusfiputra/helpers/metrics"
 	"task-5-pbi-btpns-arthagusfiputra/models"
 
 	"github.com/gin-gonic/gin"
@@ -22,76 +22,49 @@ func Login(c *gin.Context) {
 	// Set the database
 	db := c.MustGet("db").(*gorm.DB)
 
-	// Read the request body
-	body, err := ioutil.ReadAll(c.Request.Body)
-	if err != nil {
-		c.JSON(http.StatusUnprocessableEntity, gin.H{
-			"status":  "Error",
-			"message": err.Error(),
-			"data":    nil,
-		})
-		return
-	}
-
-	// Convert JSON to an object
-	userModel := models.User{}
-	err = json.Unmarshal(body, &userModel)
-	if err != nil {
-		c.JSON(http.StatusUnprocessableEntity, gin.H{
-			"status":  "Error",
-			"message": err.Error(),
-			"data":    nil,
-		})
+	var input app.LoginInput
+	if !bindJSON(c, &input) {
 		return
 	}
+	userModel := models.User{Email: input.Email, Password: input.Password}
 
 	// Initialize user
 	userModel.Init()
-	err = userModel.Validate("login")
-	if err != nil {
-		c.JSON(http.StatusUnprocessableEntity, gin.H{
-			"status":  "Error",
-			"message": err.Error(),
-			"data":    nil,
-		})
-		return
-	}
 
 	// Check if the user exists
 	var userLogin app.UserLogin
-	err = db.Debug().Table("users").Select("*").Joins("LEFT JOIN photos ON photos.user_id = users.id").
-		Where("users.email = ?", userModel.Email).Find(&userLogin).Error
+	err := db.Table("users").Select("*").Joins("LEFT JOIN photos ON photos.user_id = users.id AND photos.deleted_at IS NULL").
+		Where("users.email = ? AND users.deleted_at IS NULL", userModel.Email).Find(&userLogin).Error
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"status":  "Error",
-			"message": "User with email " + userModel.Email + " not found",
-			"data":    nil,
-		})
+		metrics.Login(false)
+		fail(c, apperror.Keyed(apperror.UserNotFound, "USER_EMAIL_NOT_FOUND").With("email", userModel.Email))
 		return
 	}
 
 	// Verify the password
+	done := metrics.TimePassword("compare")
 	err = hash.CheckPasswordHash(userLogin.Password, userModel.Password)
-	if err != nil && err == bcrypt.ErrMismatchedHashAndPassword {
-		formattedError := errorformat.ErrorMessage(err.Error())
-		c.JSON(http.StatusBadRequest, gin.H{
-			"status":  "Error",
-			"message": formattedError.Error(),
-			"data":    nil,
-		})
+	done()
+	if err != nil {
+		// A wrong password is reported as such; any other error, such as a malformed hash, still refuses the login
+		metrics.Login(false)
+		if err == bcrypt.ErrMismatchedHashAndPassword {
+			fail(c, apperror.New(apperror.InvalidCredentials))
+		} else {
+			fail(c, err)
+		}
 		return
 	}
 
+	preferLocale(c, userLogin.Locale)
+
 	// Generate a token upon successful login
 	token, err := auth.GenerateJWT(userLogin.Email, userLogin.Username)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"status":  "Error",
-			"message": err.Error(),
-			"data":    nil,
-		})
+		fail(c, err)
 		return
 	}
+	metrics.Login(true)
 
 	data := app.UserData{
 		ID:       userLogin.ID,
@@ -108,7 +81,7 @@ func Login(c *gin.Context) {
 	// Return the response
 	c.JSON(http.StatusOK, gin.H{
 		"status":  "Success",
-		"message": "Login successfully",
+		"message": translate(c, "LOGIN_SUCCESS"),
 		"data":    data,
 	})
 }
@@ -118,53 +91,32 @@ func CreateUser(c *gin.Context) {
 	// Set the database
 	db := c.MustGet("db").(*gorm.DB)
 
-	// Read the request body
-	body, err := ioutil.ReadAll(c.Request.Body)
-	if err != nil {
-		c.JSON(http.StatusUnprocessableEntity, gin.H{
-			"status":  "Error",
-			"message": err.Error(),
-			"data":    nil,
-		})
-	}
-
-	// Convert JSON to an object
-	userModel := models.User{}
-	err = json.Unmarshal(body, &userModel)
-	if err != nil {
-		c.JSON(http.StatusUnprocessableEntity, gin.H{
-			"status":  "Error",
-			"message": err.Error(),
-			"data":    nil,
-		})
+	var input app.UserInput
+	if !bindJSON(c, &input) {
 		return
 	}
+	userModel := models.User{Username: input.Username, Email: input.Email, Password: input.Password, Locale: input.Locale}
 
 	userModel.Init() // Initialize the user
 
-	err = userModel.Validate("update") // Validate the user
-	if err != nil {
-		c.JSON(http.StatusUnprocessableEntity, gin.H{
-			"status":  "Error",
-			"message": err.Error(),
-			"data":    nil,
-		})
+	// The email can only be registered once
+	if err := checkEmail(db, userModel.Email, ""); err != nil {
+		fail(c, err)
 		return
 	}
 
-	err = userModel.HashPassword() // Hash the password
+	err := userModel.HashPassword() // Hash the password
 	if err != nil {
-		log.Fatal(err)
+		fail(c, err)
+		return
 	}
 
-	err = db.Debug().Create(&userModel).Error // Create the user in the database
+	err = db.Create(&userModel).Error // Create the user in the database
+	if apperror.IsDuplicate(err) {
+		err = apperror.New(apperror.UserEmailTaken)
+	}
 	if err != nil {
-		formattedError := errorformat.ErrorMessage(err.Error())
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"status":  "Error",
-			"message": formattedError.Error(),
-			"data":    nil,
-		})
+		fail(c, err)
 		return
 	}
 
@@ -172,13 +124,14 @@ func CreateUser(c *gin.Context) {
 		ID:        userModel.ID,
 		Username:  userModel.Username,
 		Email:     userModel.Email,
+		Locale:    userModel.Locale,
 		CreatedAt: userModel.CreatedAt,
 		UpdatedAt: userModel.UpdatedAt,
 	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"status":  "Success",
-		"message": "User registered successfully",
+		"message": translate(c, "USER_REGISTERED"),
 		"data":    data,
 	}) // Response for success
 }
@@ -188,68 +141,53 @@ func UpdateUser(c *gin.Context) {
 	// Set the database
 	db := c.MustGet("db").(*gorm.DB)
 
-	// Check if the user exists
-	var user models.User
-	err := db.Debug().Where("id = ?", c.Param("userId")).First(&user).Error
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"status":  "Error",
-			"message": "User with id " + c.Param("userId") + " not found",
-			"data":    nil,
-		})
+	// Users can only update their own profile
+	user, ok := currentUser(c, db)
+	if !ok {
 		return
 	}
-
-	// Read the request body
-	body, err := ioutil.ReadAll(c.Request.Body)
-	if err != nil {
-		c.JSON(http.StatusUnprocessableEntity, gin.H{
-			"status":  "Error",
-			"message": err.Error(),
-			"data":    nil,
-		})
+	if c.Param("userId") != user.ID {
+		fail(c, apperror.New(apperror.UserForbidden))
+		return
 	}
-
-	// Convert JSON to an object
-	userModel := models.User{}
-
-	userModel.ID = user.ID
-	err = json.Unmarshal(body, &userModel)
-	if err != nil {
-		c.JSON(http.StatusUnprocessableEntity, gin.H{
-			"status":  "Error",
-			"message": err.Error(),
-			"data":    nil,
-		})
+	if !preconditionMet(c, user.Version) {
 		return
 	}
 
-	// Validate the user
-	err = userModel.Validate("update")
-	if err != nil {
-		c.JSON(http.StatusUnprocessableEntity, gin.H{
-			"status":  "Error",
-			"message": err.Error(),
-			"data":    nil,
-		})
+	var input app.UserInput
+	if !bindJSON(c, &input) {
 		return
 	}
+	userModel := models.User{Username: input.Username, Email: input.Email, Password: input.Password, Locale: input.Locale}
+	userModel.Init()
+	userModel.ID = user.ID
+	userModel.Version = user.Version + 1
 
 	// Hash the password
-	err = userModel.HashPassword()
+	err := userModel.HashPassword()
 	if err != nil {
-		log.Fatal(err)
+		fail(c, err)
+		return
 	}
 
-	// Update the user
-	err = db.Debug().Model(&user).Updates(&userModel).Error
+	// Check the email and update the user with its row locked, unless another write got there first
+	err = inTransaction(db, func(tx *gorm.DB) error {
+		stored, err := lockUser(tx, user.ID)
+		if err != nil {
+			return err
+		}
+
+		// The email can only belong to one user
+		if err := checkEmail(tx, userModel.Email, user.ID); err != nil {
+			return err
+		}
+		return checkVersioned(tx.Model(&stored).Scopes(versioned(user.Version)).Updates(&userModel))
+	})
+	if apperror.IsDuplicate(err) {
+		err = apperror.New(apperror.UserEmailTaken)
+	}
 	if err != nil {
-		formattedError := errorformat.ErrorMessage(err.Error())
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"status":  "Error",
-			"message": formattedError.Error(),
-			"data":    nil,
-		})
+		fail(c, err)
 		return
 	}
 
@@ -257,51 +195,330 @@ func UpdateUser(c *gin.Context) {
 		ID:        userModel.ID,
 		Username:  userModel.Username,
 		Email:     userModel.Email,
+		Locale:    userModel.Locale,
 		CreatedAt: userModel.CreatedAt,
 		UpdatedAt: userModel.UpdatedAt,
 	}
 
 	// Response for success
+	c.Header("ETag", entityTag(userModel.Version, data))
 	c.JSON(http.StatusOK, gin.H{
-		"status":  "Error",
-		"message": "User updated successfully",
+		"status":  "Success",
+		"message": translate(c, "USER_UPDATED"),
 		"data":    data,
 	})
 }
 
+// PatchUser applies a JSON Merge Patch to the profile of the logged in user.
+// Only the members sent are changed; the password has its own endpoint.
+func PatchUser(c *gin.Context) {
+	// Set the database
+	db := c.MustGet("db").(*gorm.DB)
+
+	user, ok := currentUser(c, db)
+	if !ok {
+		return
+	}
+	if c.Param("userId") != user.ID {
+		fail(c, apperror.New(apperror.UserForbidden))
+		return
+	}
+	if !preconditionMet(c, user.Version) {
+		return
+	}
+
+	// The stored values are escaped, the patch is applied to what the user once sent
+	current := app.UserPatch{
+		Username: html.UnescapeString(user.Username),
+		Email:    html.UnescapeString(user.Email),
+		Locale:   user.Locale,
+	}
+	var input app.UserPatch
+	if _, ok := bindMergePatch(c, current, &input); !ok {
+		return
+	}
+	patched := models.User{Username: input.Username, Email: input.Email, Locale: input.Locale}
+	patched.Init()
+
+	// Only write the columns that really change
+	updates := map[string]interface{}{}
+	if patched.Username != user.Username {
+		updates["username"] = patched.Username
+	}
+	if patched.Email != user.Email {
+		updates["email"] = patched.Email
+	}
+	if patched.Locale != user.Locale {
+		updates["locale"] = patched.Locale
+	}
+	if len(updates) > 0 {
+		updates["version"] = user.Version + 1
+
+		// Check the email and update the user with its row locked, unless another write got there first
+		var stored models.User
+		err := inTransaction(db, func(tx *gorm.DB) error {
+			var err error
+			if stored, err = lockUser(tx, user.ID); err != nil {
+				return err
+			}
+			if _, changed := updates["email"]; changed {
+				if err := checkEmail(tx, patched.Email, user.ID); err != nil {
+					return err
+				}
+			}
+			return checkVersioned(tx.Model(&stored).Scopes(versioned(user.Version)).Updates(updates))
+		})
+		if apperror.IsDuplicate(err) {
+			err = apperror.New(apperror.UserEmailTaken)
+		}
+		if err != nil {
+			fail(c, err)
+			return
+		}
+		user = stored
+	}
+	preferLocale(c, user.Locale)
+
+	data := app.UserRegister{
+		ID:        user.ID,
+		Username:  user.Username,
+		Email:     user.Email,
+		Locale:    user.Locale,
+		CreatedAt: user.CreatedAt,
+		UpdatedAt: user.UpdatedAt,
+	}
+	c.Header("ETag", entityTag(user.Version, data))
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "Success",
+		"message": translate(c, "USER_UPDATED"),
+		"data":    data,
+	})
+}
+
+// ChangePassword replaces the password of the logged in user after checking the current one.
+func ChangePassword(c *gin.Context) {
+	// Set the database
+	db := c.MustGet("db").(*gorm.DB)
+
+	user, ok := currentUser(c, db)
+	if !ok {
+		return
+	}
+
+	if !preconditionMet(c, user.Version) {
+		return
+	}
+
+	var input app.PasswordInput
+	if !bindJSON(c, &input) {
+		return
+	}
+
+	// Verify the current password
+	if err := user.CheckPassword(input.CurrentPassword); err != nil {
+		fail(c, apperror.Field("current_password", "PASSWORD_INCORRECT"))
+		return
+	}
+
+	user.Password = input.NewPassword
+	if err := user.HashPassword(); err != nil {
+		fail(c, err)
+		return
+	}
+	updates := map[string]interface{}{"password": user.Password, "version": user.Version + 1}
+	if err := checkVersioned(db.Model(&user).Scopes(versioned(user.Version)).Updates(updates)); err != nil {
+		fail(c, err)
+		return
+	}
+
+	c.Header("ETag", entityTag(user.Version, nil))
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "Success",
+		"message": translate(c, "PASSWORD_CHANGED"),
+		"data":    nil,
+	})
+}
+
 // DeleteUser handles user deletion.
 func DeleteUser(c *gin.Context) {
 	// Set the database
 	db := c.MustGet("db").(*gorm.DB)
 
+	// Users can delete their own account, administrators any account
+	caller, ok := currentUser(c, db)
+	if !ok {
+		return
+	}
+	if c.Param("userId") != caller.ID && !caller.Admin {
+		fail(c, apperror.New(apperror.UserForbidden))
+		return
+	}
+
 	// Check if the user exists
 	var user models.User
 
-	err := db.Debug().Where("id = ?", c.Param("userId")).First(&user).Error
+	err := db.Where("id = ?", c.Param("userId")).First(&user).Error
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"status":  "Error",
-			"message": "User with id " + c.Param("userId") + " not found",
-			"data":    nil,
-		})
+		fail(c, apperror.New(apperror.UserNotFound).With("id", c.Param("userId")))
+		return
+	}
+	if !preconditionMet(c, user.Version) {
 		return
 	}
 
-	// Delete the user
-	err = db.Debug().Delete(&user).Error
+	// Soft-delete the user with their photos at the same moment, so restoring the user brings the photos back.
+	// The tags of the photos and the likes of the user stop counting. The purge removes them for good after the retention period
+	now := time.Now()
+	photoIDs := []int{}
+	err = inTransaction(db, func(tx *gorm.DB) error {
+		if _, err := lockUser(tx, user.ID); err != nil {
+			return err
+		}
+		photoIDs = photoIDs[:0]
+		if err := tx.Model(&models.Photo{}).Where("user_id = ?", user.ID).Pluck("id", &photoIDs).Error; err != nil {
+			return err
+		}
+		if err := countPhotoTags(tx, photoIDs, -1); err != nil {
+			return err
+		}
+		if len(photoIDs) > 0 {
+			if err := tx.Model(&models.Photo{}).Where("id IN (?)", photoIDs).UpdateColumn("deleted_at", now).Error; err != nil {
+				return err
+			}
+		}
+		if err := checkVersioned(tx.Model(&user).Scopes(versioned(user.Version)).UpdateColumn("deleted_at", now)); err != nil {
+			return err
+		}
+		return recountLikes(tx, user.ID)
+	})
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"status":  "Error",
-			"message": err.Error(),
-			"data":    nil,
-		})
+		fail(c, err)
 		return
 	}
 
+	// UpdateColumn skips the hooks that keep the search index in step
+	for _, id := range photoIDs {
+		models.PhotoIndex.Remove(id)
+	}
+
 	// Response for success
 	c.JSON(http.StatusOK, gin.H{
 		"status":  "Success",
-		"message": "User deleted successfully",
+		"message": translate(c, "USER_DELETED"),
 		"data":    nil,
 	})
 }
+
+// GetMe retrieves the full profile of the logged in user.
+func GetMe(c *gin.Context) {
+	// Set the database
+	db := c.MustGet("db").(*gorm.DB)
+
+	user, ok := currentUser(c, db)
+	if !ok {
+		return
+	}
+
+	primary, stats, err := userProfile(db, user.ID, false)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	data := user.SelfView(primary, stats)
+	if notModified(c, user.Version, data) {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "Success",
+		"message": translate(c, "DATA_RETRIEVED"),
+		"data":    data,
+	})
+}
+
+// GetUser retrieves the public profile of a user.
+func GetUser(c *gin.Context) {
+	// Set the database
+	db := c.MustGet("db").(*gorm.DB)
+
+	// Check if the user exists
+	var user models.User
+	if err := db.Where("id = ?", c.Param("userId")).First(&user).Error; err != nil {
+		fail(c, apperror.New(apperror.UserNotFound).With("id", c.Param("userId")))
+		return
+	}
+
+	primary, stats, err := userProfile(db, user.ID, true)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	data := user.PublicView(primary, stats)
+	if notModified(c, user.Version, data) {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "Success",
+		"message": translate(c, "DATA_RETRIEVED"),
+		"data":    data,
+	})
+}
+
+// checkEmail checks that no other user than exceptID registered the email.
+// Deleted users keep their email until they are purged, so they can be restored.
+// The unique index still catches concurrent registrations.
+func checkEmail(db *gorm.DB, email string, exceptID string) error {
+	var count int
+	if err := db.Unscoped().Model(&models.User{}).Where("email = ? AND id <> ?", email, exceptID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return apperror.New(apperror.UserEmailTaken)
+	}
+	return nil
+}
+
+// userProfile loads the primary photo and the counters shown on a profile.
+// Public profiles only use public photos and albums.
+func userProfile(db *gorm.DB, userID string, public bool) (*models.Photo, app.UserStats, error) {
+	var stats app.UserStats
+
+	ownPhotos := db.Model(&models.Photo{}).Where("user_id = ?", userID)
+	if public {
+		ownPhotos = ownPhotos.Where("visibility = ?", models.VisibilityPublic)
+	}
+
+	// The most recent photo is the primary one
+	var primary *models.Photo
+	photos := []models.Photo{}
+	if err := ownPhotos.Order("created_at desc").Order("id desc").Limit(1).Find(&photos).Error; err != nil {
+		return nil, stats, err
+	}
+	if len(photos) > 0 {
+		primary = &photos[0]
+	}
+
+	if err := ownPhotos.Count(&stats.PhotoCount).Error; err != nil {
+		return nil, stats, err
+	}
+	albums := db.Model(&models.Album{}).Where("user_id = ?", userID)
+	if public {
+		albums = albums.Where("visibility = ?", models.VisibilityPublic)
+	}
+	if err := albums.Count(&stats.AlbumCount).Error; err != nil {
+		return nil, stats, err
+	}
+	followers := db.Model(&models.Follow{}).Scopes(activeUsers("follower_id")).Where("followee_id = ?", userID)
+	if err := followers.Count(&stats.FollowerCount).Error; err != nil {
+		return nil, stats, err
+	}
+	following := db.Model(&models.Follow{}).Scopes(activeUsers("followee_id")).Where("follower_id = ?", userID)
+	if err := following.Count(&stats.FollowingCount).Error; err != nil {
+		return nil, stats, err
+	}
+
+	return primary, stats, nil
+}
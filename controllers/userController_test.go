@@ -0,0 +1,60 @@
+package controllers_test
+
+import (
+	"net/http"
+	"testing"
+
+	"task-5-pbi-btpns-arthagusfiputra/models"
+)
+
+// TestUserWritesNeedOwner checks that only the user, or an administrator for deletes, can change an account.
+func TestUserWritesNeedOwner(t *testing.T) {
+	h, db := newServer(t)
+	alice, _ := seedUser(t, db, "alice")
+	_, bobToken := seedUser(t, db, "bob")
+	admin, adminToken := seedUser(t, db, "admin")
+	if err := db.Model(&admin).UpdateColumn("admin", true).Error; err != nil {
+		t.Fatal(err)
+	}
+	update := map[string]string{"username": "mallory", "email": "mallory@example.com", "password": "passw0rd1"}
+
+	cases := []struct {
+		name   string
+		method string
+		token  string
+		body   interface{}
+		want   int
+	}{
+		{"anonymous update", http.MethodPut, "", update, http.StatusUnauthorized},
+		{"anonymous delete", http.MethodDelete, "", nil, http.StatusUnauthorized},
+		{"other user update", http.MethodPut, bobToken, update, http.StatusForbidden},
+		{"other user delete", http.MethodDelete, bobToken, nil, http.StatusForbidden},
+		{"administrator update", http.MethodPut, adminToken, update, http.StatusForbidden},
+		{"administrator delete", http.MethodDelete, adminToken, nil, http.StatusOK},
+	}
+	for _, tc := range cases {
+		if code, body := call(h, tc.method, "/users/"+alice.ID, tc.token, tc.body); code != tc.want {
+			t.Errorf("%s: got status %d, want %d: %v", tc.name, code, tc.want, body)
+		}
+	}
+
+	var stored models.User
+	if err := db.Unscoped().Where("id = ?", alice.ID).First(&stored).Error; err != nil {
+		t.Fatal(err)
+	}
+	if stored.Username != alice.Username || stored.DeletedAt == nil {
+		t.Errorf("got %q deleted at %v, want the unchanged user deleted by the administrator", stored.Username, stored.DeletedAt)
+	}
+}
+
+// TestLoginMalformedHash checks that a password hash bcrypt can't read refuses the login.
+func TestLoginMalformedHash(t *testing.T) {
+	h, db := newServer(t)
+	// Seeded users have "-" as their password hash
+	alice, _ := seedUser(t, db, "alice")
+
+	code, body := call(h, http.MethodPost, "/users/login", "", map[string]string{"email": alice.Email, "password": "passw0rd1"})
+	if code == http.StatusOK || body["data"] != nil {
+		t.Errorf("got status %d: %v, want the login refused", code, body)
+	}
+}
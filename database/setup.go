@@ -31,17 +31,113 @@ func ConnectDB() *gorm.DB {
 		log.Fatal(err)
 	}
 
+	// Create or update the database tables
+	if err := Migrate(db); err != nil {
+		log.Fatal(err)
+	}
+
+	return db
+}
+
+// Migrate creates or updates the tables, constraints and indexes of the models.
+func Migrate(db *gorm.DB) error {
 	// Perform auto migrations to create or update database tables
-	err = db.Debug().AutoMigrate(&models.User{}, &models.Photo{}).Error
+	err := db.AutoMigrate(&models.User{}, &models.Photo{}, &models.Album{}, &models.AlbumPhoto{}, &models.Tag{}, &models.PhotoTag{}, &models.Like{}, &models.Comment{}, &models.Follow{}, &models.Notification{}, &models.NotificationActor{}, &models.IdempotencyKey{}).Error
 	if err != nil {
-		log.Fatalf("Migrating table error: %v", err)
+		return fmt.Errorf("migrating tables: %w", err)
 	}
 
-	// Add foreign key constraint for Photo model
-	err = db.Debug().Model(&models.Photo{}).AddForeignKey("user_id", "users(id)", "cascade", "cascade").Error
+	// Only MySQL can add constraints and full-text indexes to existing tables
+	mysql := db.Dialect().GetName() == "mysql"
+	if mysql {
+		if err := addForeignKeys(db); err != nil {
+			return fmt.Errorf("attaching foreign key: %w", err)
+		}
+	}
+
+	// Add the index used by the feed and the photo lists of a user
+	err = db.Model(&models.Photo{}).AddIndex("idx_photos_user_created", "user_id", "created_at").Error
 	if err != nil {
-		log.Fatalf("Error while attaching foreign key: %v", err)
+		return fmt.Errorf("adding index: %w", err)
 	}
 
-	return db
+	// Add the full-text index used by photo search
+	if mysql && !db.Dialect().HasIndex("photos", "idx_photos_fulltext") {
+		err = db.Exec("ALTER TABLE photos ADD FULLTEXT INDEX idx_photos_fulltext (title, caption)").Error
+		if err != nil {
+			return fmt.Errorf("adding full-text index: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// addForeignKeys attaches the foreign key constraints between the tables.
+func addForeignKeys(db *gorm.DB) error {
+	// Add foreign key constraint for Photo model
+	err := db.Model(&models.Photo{}).AddForeignKey("user_id", "users(id)", "cascade", "cascade").Error
+
+	// Add foreign key constraints for Album and AlbumPhoto models
+	if err == nil {
+		err = db.Model(&models.Album{}).AddForeignKey("user_id", "users(id)", "cascade", "cascade").Error
+	}
+	if err == nil {
+		err = db.Model(&models.Album{}).AddForeignKey("cover_photo_id", "photos(id)", "set null", "cascade").Error
+	}
+	if err == nil {
+		err = db.Model(&models.AlbumPhoto{}).AddForeignKey("album_id", "albums(id)", "cascade", "cascade").Error
+	}
+	if err == nil {
+		err = db.Model(&models.AlbumPhoto{}).AddForeignKey("photo_id", "photos(id)", "cascade", "cascade").Error
+	}
+
+	// Add foreign key constraints for PhotoTag model
+	if err == nil {
+		err = db.Model(&models.PhotoTag{}).AddForeignKey("photo_id", "photos(id)", "cascade", "cascade").Error
+	}
+	if err == nil {
+		err = db.Model(&models.PhotoTag{}).AddForeignKey("tag_id", "tags(id)", "cascade", "cascade").Error
+	}
+
+	// Add foreign key constraints for Like model
+	if err == nil {
+		err = db.Model(&models.Like{}).AddForeignKey("photo_id", "photos(id)", "cascade", "cascade").Error
+	}
+	if err == nil {
+		err = db.Model(&models.Like{}).AddForeignKey("user_id", "users(id)", "cascade", "cascade").Error
+	}
+
+	// Add foreign key constraints for Comment model
+	if err == nil {
+		err = db.Model(&models.Comment{}).AddForeignKey("photo_id", "photos(id)", "cascade", "cascade").Error
+	}
+	if err == nil {
+		err = db.Model(&models.Comment{}).AddForeignKey("user_id", "users(id)", "cascade", "cascade").Error
+	}
+	if err == nil {
+		err = db.Model(&models.Comment{}).AddForeignKey("parent_id", "comments(id)", "cascade", "cascade").Error
+	}
+
+	// Add foreign key constraints for Follow model
+	if err == nil {
+		err = db.Model(&models.Follow{}).AddForeignKey("follower_id", "users(id)", "cascade", "cascade").Error
+	}
+	if err == nil {
+		err = db.Model(&models.Follow{}).AddForeignKey("followee_id", "users(id)", "cascade", "cascade").Error
+	}
+
+	// Add foreign key constraints for Notification and NotificationActor models
+	if err == nil {
+		err = db.Model(&models.Notification{}).AddForeignKey("user_id", "users(id)", "cascade", "cascade").Error
+	}
+	if err == nil {
+		err = db.Model(&models.Notification{}).AddForeignKey("photo_id", "photos(id)", "cascade", "cascade").Error
+	}
+	if err == nil {
+		err = db.Model(&models.Notification{}).AddForeignKey("comment_id", "comments(id)", "cascade", "cascade").Error
+	}
+	if err == nil {
+		err = db.Model(&models.NotificationActor{}).AddForeignKey("notification_id", "notifications(id)", "cascade", "cascade").Error
+	}
+	return err
 }
@@ -0,0 +1,249 @@
+package apperror
+
+import (
+	"errors"
+	"net/http"
+
+	"task-5-pbi-btpns-arthagusfiputra/helpers/i18n"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jinzhu/gorm"
+)
+
+// Code is a stable, machine-readable error identifier.
+type Code string
+
+// Error codes returned by the API.
+const (
+	InvalidRequest   Code = "INVALID_REQUEST"
+	ValidationFailed Code = "VALIDATION_FAILED"
+	Unauthorized     Code = "UNAUTHORIZED"
+	NotFound         Code = "NOT_FOUND"
+	MethodNotAllowed Code = "METHOD_NOT_ALLOWED"
+	Conflict         Code = "CONFLICT"
+	Forbidden        Code = "FORBIDDEN"
+	UnsupportedMedia Code = "UNSUPPORTED_MEDIA_TYPE"
+	Internal         Code = "INTERNAL_ERROR"
+
+	PreconditionFailed   Code = "PRECONDITION_FAILED"
+	PreconditionRequired Code = "PRECONDITION_REQUIRED"
+
+	IdempotencyKeyReused  Code = "IDEMPOTENCY_KEY_REUSED"
+	IdempotencyInProgress Code = "IDEMPOTENCY_IN_PROGRESS"
+
+	InvalidCredentials Code = "INVALID_CREDENTIALS"
+	UserNotFound       Code = "USER_NOT_FOUND"
+	UserEmailTaken     Code = "USER_EMAIL_TAKEN"
+	UserForbidden      Code = "USER_FORBIDDEN"
+
+	PhotoNotFound     Code = "PHOTO_NOT_FOUND"
+	PhotoForbidden    Code = "PHOTO_FORBIDDEN"
+	PhotoSourceFailed Code = "PHOTO_SOURCE_FAILED"
+
+	AlbumNotFound  Code = "ALBUM_NOT_FOUND"
+	AlbumForbidden Code = "ALBUM_FORBIDDEN"
+
+	CommentNotFound  Code = "COMMENT_NOT_FOUND"
+	CommentForbidden Code = "COMMENT_FORBIDDEN"
+
+	NotificationNotFound Code = "NOTIFICATION_NOT_FOUND"
+
+	FileNotFound  Code = "FILE_NOT_FOUND"
+	FileForbidden Code = "FILE_FORBIDDEN"
+)
+
+// statuses maps every code to its HTTP status. Unknown codes are internal errors.
+var statuses = map[Code]int{
+	InvalidRequest:   http.StatusBadRequest,
+	ValidationFailed: http.StatusUnprocessableEntity,
+	Unauthorized:     http.StatusUnauthorized,
+	NotFound:         http.StatusNotFound,
+	MethodNotAllowed: http.StatusMethodNotAllowed,
+	Conflict:         http.StatusConflict,
+	Forbidden:        http.StatusForbidden,
+	UnsupportedMedia: http.StatusUnsupportedMediaType,
+	Internal:         http.StatusInternalServerError,
+
+	PreconditionFailed:   http.StatusPreconditionFailed,
+	PreconditionRequired: http.StatusPreconditionRequired,
+
+	IdempotencyKeyReused:  http.StatusUnprocessableEntity,
+	IdempotencyInProgress: http.StatusConflict,
+
+	InvalidCredentials: http.StatusUnauthorized,
+	UserNotFound:       http.StatusNotFound,
+	UserEmailTaken:     http.StatusConflict,
+	UserForbidden:      http.StatusForbidden,
+
+	PhotoNotFound:     http.StatusNotFound,
+	PhotoForbidden:    http.StatusForbidden,
+	PhotoSourceFailed: http.StatusUnprocessableEntity,
+
+	AlbumNotFound:  http.StatusNotFound,
+	AlbumForbidden: http.StatusForbidden,
+
+	CommentNotFound:  http.StatusNotFound,
+	CommentForbidden: http.StatusForbidden,
+
+	NotificationNotFound: http.StatusNotFound,
+
+	FileNotFound:  http.StatusNotFound,
+	FileForbidden: http.StatusForbidden,
+}
+
+// Status returns the HTTP status of the code.
+func (c Code) Status() int {
+	if status, ok := statuses[c]; ok {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
+// FieldError describes what is wrong with a single request field.
+// Message is only filled by Error.Fields, in the locale of the response.
+type FieldError struct {
+	Field   string      `json:"field"`
+	Key     string      `json:"-"`
+	Params  i18n.Params `json:"-"`
+	Message string      `json:"message"`
+}
+
+// Error is an error with a code, a message safe to show to clients and optional field details.
+// The message is looked up by Key in the i18n catalogs, so it can be shown in the client's language.
+type Error struct {
+	Code    Code
+	Key     string      // Catalog key of the message, the code when empty
+	Params  i18n.Params // Values for the placeholders of the message
+	Details []FieldError
+	Err     error // The underlying cause, never shown to clients
+}
+
+// New returns an error with the given code, described by the catalog message of the code.
+func New(code Code) *Error {
+	return &Error{Code: code}
+}
+
+// Keyed returns an error with the given code, described by the catalog message under key.
+func Keyed(code Code, key string) *Error {
+	return &Error{Code: code, Key: key}
+}
+
+// Field returns a validation error about a single field, described by the catalog message under key.
+// The field name is available to the message as {field}.
+func Field(field string, key string) *Error {
+	params := i18n.Params{"field": field} // Shared with the detail, so With fills both
+	return &Error{
+		Code:    ValidationFailed,
+		Key:     key,
+		Params:  params,
+		Details: []FieldError{{Field: field, Key: key, Params: params}},
+	}
+}
+
+// Fields returns a validation error about several fields at once.
+// A single field is described by its own message, several by a summary.
+func Fields(details []FieldError) *Error {
+	if len(details) == 1 {
+		return &Error{Code: ValidationFailed, Key: details[0].Key, Params: details[0].Params, Details: details}
+	}
+	return &Error{Code: ValidationFailed, Key: "FIELDS_INVALID", Params: i18n.Params{"count": len(details)}, Details: details}
+}
+
+// Wrap returns an internal error caused by err.
+func Wrap(err error) *Error {
+	return &Error{Code: Internal, Err: err}
+}
+
+// Malformed returns a request error for a body that can't be read or decoded.
+func Malformed(err error) *Error {
+	return &Error{Code: InvalidRequest, Params: i18n.Params{"reason": err.Error()}, Err: err}
+}
+
+// Invalid returns err as a validation error, keeping the code and details of an *Error.
+func Invalid(err error) *Error {
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return appErr
+	}
+	return &Error{Code: ValidationFailed, Params: i18n.Params{"reason": err.Error()}, Err: err}
+}
+
+// With sets the value of a message placeholder and returns the error.
+func (e *Error) With(name string, value interface{}) *Error {
+	if e.Params == nil {
+		e.Params = i18n.Params{}
+	}
+	e.Params[name] = value
+	return e
+}
+
+// Because records the underlying cause and returns the error.
+func (e *Error) Because(err error) *Error {
+	e.Err = err
+	return e
+}
+
+// Message returns the message of the error in the given locale.
+func (e *Error) Message(locale string) string {
+	key := e.Key
+	if key == "" {
+		key = string(e.Code)
+	}
+	return i18n.T(locale, key, e.Params)
+}
+
+// Fields returns the field details with their messages in the given locale.
+func (e *Error) Fields(locale string) []FieldError {
+	fields := make([]FieldError, len(e.Details))
+	for i, detail := range e.Details {
+		fields[i] = detail
+		fields[i].Message = i18n.T(locale, detail.Key, detail.Params)
+	}
+	return fields
+}
+
+// Error implements the error interface.
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return string(e.Code) + ": " + e.Message(i18n.Default) + ": " + e.Err.Error()
+	}
+	return string(e.Code) + ": " + e.Message(i18n.Default)
+}
+
+// Unwrap returns the underlying cause.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Status returns the HTTP status of the error.
+func (e *Error) Status() int {
+	return e.Code.Status()
+}
+
+// From turns any error into an *Error.
+// Missing records become NOT_FOUND, duplicate keys CONFLICT and everything else an internal error.
+func From(err error) *Error {
+	var appErr *Error
+	switch {
+	case errors.As(err, &appErr):
+		return appErr
+	case gorm.IsRecordNotFoundError(err):
+		return &Error{Code: NotFound, Err: err}
+	case IsDuplicate(err):
+		return &Error{Code: Conflict, Err: err}
+	}
+	return Wrap(err)
+}
+
+// IsDuplicate reports whether err is a unique key violation reported by the database.
+func IsDuplicate(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == 1062
+}
+
+// IsDeadlock reports whether the database aborted the transaction to break a deadlock or after waiting too long for a lock.
+// The transaction was rolled back and can be run again.
+func IsDeadlock(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && (mysqlErr.Number == 1213 || mysqlErr.Number == 1205)
+}
@@ -0,0 +1,132 @@
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Default is the locale used when nothing better matches. Its catalog is expected to hold every key.
+const Default = "en"
+
+// Params are the values filled into the {name} placeholders of a message.
+type Params map[string]interface{}
+
+//go:embed locales/*.json
+var files embed.FS
+
+// catalogs maps a locale such as "id" or "pt-br" to its messages by key.
+var catalogs = map[string]map[string]string{}
+
+// Every locales/<locale>.json file is a catalog, so adding a locale only takes a new file.
+func init() {
+	entries, err := files.ReadDir("locales")
+	if err != nil {
+		panic(err)
+	}
+	for _, entry := range entries {
+		raw, err := files.ReadFile(path.Join("locales", entry.Name()))
+		if err != nil {
+			panic(err)
+		}
+		messages := map[string]string{}
+		if err := json.Unmarshal(raw, &messages); err != nil {
+			panic(fmt.Sprintf("i18n: %s: %v", entry.Name(), err))
+		}
+		catalogs[normalize(strings.TrimSuffix(entry.Name(), ".json"))] = messages
+	}
+	if _, ok := catalogs[Default]; !ok {
+		panic("i18n: missing catalog for the default locale " + Default)
+	}
+}
+
+// Locales returns the supported locales in alphabetical order.
+func Locales() []string {
+	locales := make([]string, 0, len(catalogs))
+	for locale := range catalogs {
+		locales = append(locales, locale)
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+// Match returns the supported locale for a language tag, trying the tag itself and then its base language.
+func Match(tag string) (string, bool) {
+	tag = normalize(tag)
+	if _, ok := catalogs[tag]; ok {
+		return tag, true
+	}
+	if i := strings.Index(tag, "-"); i > 0 {
+		if _, ok := catalogs[tag[:i]]; ok {
+			return tag[:i], true
+		}
+	}
+	return "", false
+}
+
+// Supported reports whether there is a catalog for the locale.
+func Supported(locale string) bool {
+	_, ok := catalogs[normalize(locale)]
+	return ok
+}
+
+// Negotiate picks the supported locale the Accept-Language header prefers most, or Default.
+func Negotiate(header string) string {
+	best, bestQ := Default, 0.0
+	for _, part := range strings.Split(header, ",") {
+		tag, q := part, 1.0
+		if i := strings.Index(part, ";"); i >= 0 {
+			tag = part[:i]
+			param := strings.TrimSpace(part[i+1:])
+			if !strings.HasPrefix(param, "q=") {
+				continue
+			}
+			var err error
+			if q, err = strconv.ParseFloat(param[2:], 64); err != nil {
+				continue
+			}
+		}
+
+		// Ties keep the earlier tag, as clients list their preferences in order
+		if locale, ok := Match(strings.TrimSpace(tag)); ok && q > bestQ {
+			best, bestQ = locale, q
+		}
+	}
+	return best
+}
+
+// T returns the message for key in the locale, with params filled in.
+// Missing messages fall back to the base language, then to Default and finally to the key itself.
+func T(locale string, key string, params Params) string {
+	message, ok := lookup(locale, key)
+	if !ok {
+		return key
+	}
+	for name, value := range params {
+		message = strings.ReplaceAll(message, "{"+name+"}", fmt.Sprint(value))
+	}
+	return message
+}
+
+// lookup finds the message for key along the fallback chain of the locale.
+func lookup(locale string, key string) (string, bool) {
+	chain := []string{Default}
+	if matched, ok := Match(locale); ok {
+		chain = []string{matched, strings.SplitN(matched, "-", 2)[0], Default}
+	}
+	for _, candidate := range chain {
+		if message, ok := catalogs[candidate][key]; ok {
+			return message, true
+		}
+	}
+	return "", false
+}
+
+// normalize lowercases a language tag and uses '-' as separator, so "id_ID" and "id-id" match.
+func normalize(tag string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(tag), "_", "-"))
+}
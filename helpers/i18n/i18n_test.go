@@ -0,0 +1,88 @@
+package i18n
+
+import (
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{"", "en"},
+		{"id", "id"},
+		{"id-ID", "id"},
+		{"ID_id", "id"},
+		{"fr", "en"},
+		{"*", "en"},
+		{"fr, id;q=0.8, en;q=0.5", "id"},
+		{"en;q=0.5, id;q=0.8", "id"},
+		{"id;q=0.5, en", "en"},
+		{"en, id", "en"},
+		{"id, en", "id"},
+		{"en;q=0.7, id;q=0.7", "en"},
+		{"id;q=0.7, en;q=0.7", "id"},
+		{"id;q=0", "en"},
+		{"id;q=0, en;q=0", "en"},
+		{"id;q=0.001", "id"},
+		{"id;q=abc, en;q=0.1", "en"},
+		{"id;level=1, en;q=0.1", "en"},
+		{" id ; q=0.9 , en ; q=0.8 ", "id"},
+	}
+	for _, tt := range tests {
+		if got := Negotiate(tt.header); got != tt.want {
+			t.Errorf("Negotiate(%q) = %q, want %q", tt.header, got, tt.want)
+		}
+	}
+}
+
+// TestCatalogParity checks that every catalog translates exactly the keys of the default catalog.
+func TestCatalogParity(t *testing.T) {
+	for _, locale := range Locales() {
+		if locale == Default {
+			continue
+		}
+		var missing, extra []string
+		for key := range catalogs[Default] {
+			if _, ok := catalogs[locale][key]; !ok {
+				missing = append(missing, key)
+			}
+		}
+		for key := range catalogs[locale] {
+			if _, ok := catalogs[Default][key]; !ok {
+				extra = append(extra, key)
+			}
+		}
+		sort.Strings(missing)
+		sort.Strings(extra)
+		if len(missing) > 0 {
+			t.Errorf("%s catalog misses %s", locale, strings.Join(missing, ", "))
+		}
+		if len(extra) > 0 {
+			t.Errorf("%s catalog has keys unknown to %s: %s", locale, Default, strings.Join(extra, ", "))
+		}
+	}
+}
+
+// TestPlaceholderParity checks that the translations use the same placeholders as the default messages.
+func TestPlaceholderParity(t *testing.T) {
+	placeholders := func(message string) string {
+		var names []string
+		for _, part := range strings.Split(message, "{")[1:] {
+			if i := strings.Index(part, "}"); i >= 0 {
+				names = append(names, part[:i])
+			}
+		}
+		sort.Strings(names)
+		return strings.Join(names, ",")
+	}
+	for _, locale := range Locales() {
+		for key, message := range catalogs[locale] {
+			if want := placeholders(catalogs[Default][key]); placeholders(message) != want {
+				t.Errorf("%s %s uses placeholders {%s}, want {%s}", locale, key, placeholders(message), want)
+			}
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// Level returns the lowest level logged, from LOG_LEVEL (debug, info, warn or error). It defaults to info.
+func Level() slog.Level {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(strings.TrimSpace(os.Getenv("LOG_LEVEL")))); err != nil {
+		return slog.LevelInfo
+	}
+	return level
+}
+
+// New returns a logger writing JSON lines to w at the configured level.
+func New(w io.Writer) *slog.Logger {
+	return slog.New(slog.NewJSONHandler(w, &slog.HandlerOptions{Level: Level()}))
+}
+
+// Setup makes a JSON logger on stdout the default, so the standard log package writes JSON too.
+func Setup() *slog.Logger {
+	logger := New(os.Stdout)
+	slog.SetDefault(logger)
+	return logger
+}
+
+type contextKey struct{}
+
+// WithLogger returns a copy of ctx carrying logger, usually one with the request ID attached.
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, logger)
+}
+
+// FromContext returns the logger carried by ctx, or the default logger.
+func FromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(contextKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
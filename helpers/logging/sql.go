@@ -0,0 +1,60 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// SQLLogger writes the statements and errors reported by gorm to a structured logger.
+// Statements are logged with their placeholders only; the parameters may hold passwords and personal data.
+type SQLLogger struct {
+	Logger *slog.Logger
+}
+
+// Print implements the gorm logger. gorm passes the kind of entry and the calling source line first.
+func (l SQLLogger) Print(values ...interface{}) {
+	if len(values) < 2 {
+		return
+	}
+	source := fmt.Sprint(values[1])
+
+	switch values[0] {
+	case "sql":
+		if len(values) < 6 {
+			return
+		}
+		duration, _ := values[2].(time.Duration)
+		params, _ := values[4].([]interface{})
+		l.Logger.Debug("sql",
+			"query", values[3],
+			"params", len(params),
+			"rows", values[5],
+			"duration_ms", float64(duration.Microseconds())/1000,
+			"source", source,
+		)
+	default:
+		// Errors come as "error" entries, or as "log" entries once statements are logged
+		if len(values) > 2 {
+			if err, ok := values[2].(error); ok {
+				l.Logger.Warn("sql error", "error", err.Error(), "source", source)
+				return
+			}
+		}
+		l.Logger.Debug("sql log", "message", fmt.Sprint(values[2:]...), "source", source)
+	}
+}
+
+// SQL returns a handle on db whose statements are logged to logger.
+// Statements are only logged when the logger is enabled for debug; errors are always logged.
+func SQL(db *gorm.DB, logger *slog.Logger) *gorm.DB {
+	scoped := db.New()
+	scoped.SetLogger(SQLLogger{Logger: logger})
+	if logger.Enabled(context.Background(), slog.LevelDebug) {
+		scoped.LogMode(true)
+	}
+	return scoped
+}
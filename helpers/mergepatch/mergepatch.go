@@ -0,0 +1,69 @@
+package mergepatch
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+)
+
+// ContentType is the media type of a JSON Merge Patch document.
+const ContentType = "application/merge-patch+json"
+
+// ErrNotObject is returned for patches that are valid JSON but not an object.
+var ErrNotObject = errors.New("merge patch must be a JSON object")
+
+// Apply returns doc with patch applied as described by RFC 7396.
+// Members set to null are removed, objects are merged recursively and any other value replaces the old one.
+func Apply(doc []byte, patch []byte) ([]byte, error) {
+	var target, changes interface{}
+	if err := decode(doc, &target); err != nil {
+		return nil, err
+	}
+	if err := decode(patch, &changes); err != nil {
+		return nil, err
+	}
+	if _, ok := changes.(map[string]interface{}); !ok {
+		return nil, ErrNotObject
+	}
+	return json.Marshal(merge(target, changes))
+}
+
+// Keys returns the top-level members named by patch, including those set to null.
+func Keys(patch []byte) (map[string]bool, error) {
+	var members map[string]json.RawMessage
+	// A null patch decodes into a nil map without error, but is no object either
+	if err := json.Unmarshal(patch, &members); err != nil || members == nil {
+		return nil, ErrNotObject
+	}
+	keys := make(map[string]bool, len(members))
+	for key := range members {
+		keys[key] = true
+	}
+	return keys, nil
+}
+
+func merge(target interface{}, patch interface{}) interface{} {
+	changes, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	merged, ok := target.(map[string]interface{})
+	if !ok {
+		merged = map[string]interface{}{}
+	}
+	for key, value := range changes {
+		if value == nil {
+			delete(merged, key)
+		} else {
+			merged[key] = merge(merged[key], value)
+		}
+	}
+	return merged
+}
+
+// decode parses JSON keeping numbers exact, so large IDs survive the round trip.
+func decode(data []byte, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode(v)
+}
@@ -0,0 +1,98 @@
+package mergepatch
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// TestApply runs the examples of RFC 7396 Appendix A.
+// Patches that are not objects replace the whole document there; Apply refuses them instead.
+func TestApply(t *testing.T) {
+	tests := []struct {
+		doc   string
+		patch string
+		want  string // empty when the patch is refused
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, ``},
+		{`{"a":"b"}`, `["c"]`, ``},
+		{`{"a":"foo"}`, `null`, ``},
+		{`{"a":"foo"}`, `"bar"`, ``},
+		{`{"e":null}`, `{"a":1}`, `{"e":null,"a":1}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+		// Numbers are kept exact
+		{`{"id":12345678901234567890}`, `{"a":0.1}`, `{"id":12345678901234567890,"a":0.1}`},
+	}
+	for _, tt := range tests {
+		got, err := Apply([]byte(tt.doc), []byte(tt.patch))
+		if tt.want == "" {
+			if err != ErrNotObject {
+				t.Errorf("Apply(%s, %s) = %s, %v, want ErrNotObject", tt.doc, tt.patch, got, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Apply(%s, %s): %v", tt.doc, tt.patch, err)
+			continue
+		}
+		var gotValue, wantValue interface{}
+		decode(got, &gotValue)
+		decode([]byte(tt.want), &wantValue)
+		if !reflect.DeepEqual(gotValue, wantValue) {
+			t.Errorf("Apply(%s, %s) = %s, want %s", tt.doc, tt.patch, got, tt.want)
+		}
+	}
+
+	if _, err := Apply([]byte(`{}`), []byte(`{"a":`)); err == nil || err == ErrNotObject {
+		t.Errorf("Apply with a truncated patch: got %v, want a syntax error", err)
+	}
+}
+
+func TestKeys(t *testing.T) {
+	tests := []struct {
+		patch string
+		want  []string // nil when the patch is refused
+	}{
+		{`{}`, []string{}},
+		{`{"title":"x"}`, []string{"title"}},
+		{`{"title":"x","tags":null}`, []string{"tags", "title"}},
+		{`{"tags":null}`, []string{"tags"}},
+		{`{"a":{"b":null}}`, []string{"a"}},
+		{`null`, nil},
+		{`[]`, nil},
+		{`"tags"`, nil},
+		{`{"tags":`, nil},
+	}
+	for _, tt := range tests {
+		keys, err := Keys([]byte(tt.patch))
+		if tt.want == nil {
+			if err != ErrNotObject {
+				t.Errorf("Keys(%s) = %v, %v, want ErrNotObject", tt.patch, keys, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Keys(%s): %v", tt.patch, err)
+			continue
+		}
+		got := []string{}
+		for key, named := range keys {
+			if named {
+				got = append(got, key)
+			}
+		}
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Keys(%s) = %v, want %v", tt.patch, got, tt.want)
+		}
+	}
+}
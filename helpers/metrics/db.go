@@ -0,0 +1,49 @@
+package metrics
+
+import (
+	"errors"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+const startKey = "metrics:start"
+
+// InstrumentDB times the statements run through db and exposes the stats of its connection pool.
+// The callbacks are shared by every handle made from db, so it is called once at startup.
+func InstrumentDB(db *gorm.DB) error {
+	callbacks := db.Callback()
+	callbacks.Create().Before("gorm:create").Register("metrics:before_create", startTimer)
+	callbacks.Create().After("gorm:create").Register("metrics:after_create", observe("create"))
+	callbacks.Query().Before("gorm:query").Register("metrics:before_query", startTimer)
+	callbacks.Query().After("gorm:query").Register("metrics:after_query", observe("query"))
+	callbacks.RowQuery().Before("gorm:row_query").Register("metrics:before_row_query", startTimer)
+	callbacks.RowQuery().After("gorm:row_query").Register("metrics:after_row_query", observe("row_query"))
+	callbacks.Update().Before("gorm:update").Register("metrics:before_update", startTimer)
+	callbacks.Update().After("gorm:update").Register("metrics:after_update", observe("update"))
+	callbacks.Delete().Before("gorm:delete").Register("metrics:before_delete", startTimer)
+	callbacks.Delete().After("gorm:delete").Register("metrics:after_delete", observe("delete"))
+
+	err := prometheus.Register(collectors.NewDBStatsCollector(db.DB(), db.Dialect().GetName()))
+	if errors.As(err, &prometheus.AlreadyRegisteredError{}) {
+		return nil
+	}
+	return err
+}
+
+func startTimer(scope *gorm.Scope) {
+	scope.InstanceSet(startKey, time.Now())
+}
+
+func observe(operation string) func(*gorm.Scope) {
+	return func(scope *gorm.Scope) {
+		value, ok := scope.InstanceGet(startKey)
+		if !ok {
+			return
+		}
+		start := value.(time.Time)
+		DBQueryDuration.WithLabelValues(operation, scope.TableName()).Observe(time.Since(start).Seconds())
+	}
+}
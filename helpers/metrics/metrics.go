@@ -0,0 +1,62 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Collectors registered with the default registry and exposed on /metrics.
+var (
+	HTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "HTTP requests answered, by method, route template and status.",
+	}, []string{"method", "route", "status"})
+
+	HTTPDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "Time taken to answer HTTP requests, by method, route template and status.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	DBQueryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "db_query_duration_seconds",
+		Help:    "Time taken by SQL statements, by operation and table.",
+		Buckets: []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"operation", "table"})
+
+	LoginAttempts = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "login_attempts_total",
+		Help: "Login attempts, by result (success or failure).",
+	}, []string{"result"})
+
+	PasswordHashDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "password_hash_duration_seconds",
+		Help:    "Time taken by bcrypt, by operation (hash or compare).",
+		Buckets: []float64{.05, .1, .25, .5, 1, 2, 4, 8},
+	}, []string{"operation"})
+
+	UploadSize = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "photo_upload_size_bytes",
+		Help:    "Size of the photo files stored; the sum is the number of bytes uploaded.",
+		Buckets: prometheus.ExponentialBuckets(16<<10, 4, 8),
+	})
+)
+
+// TimePassword starts timing a bcrypt operation and returns the function that records it.
+func TimePassword(operation string) func() {
+	start := time.Now()
+	return func() {
+		PasswordHashDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+	}
+}
+
+// Login counts a login attempt.
+func Login(success bool) {
+	if success {
+		LoginAttempts.WithLabelValues("success").Inc()
+	} else {
+		LoginAttempts.WithLabelValues("failure").Inc()
+	}
+}
@@ -0,0 +1,63 @@
+package pagination
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strconv"
+
+	"task-5-pbi-btpns-arthagusfiputra/helpers/apperror"
+)
+
+// Limit bounds shared by the list endpoints.
+const (
+	DefaultLimit = 20
+	MaxLimit     = 100
+)
+
+// Cursor marks the last row of a page for keyset pagination.
+type Cursor struct {
+	Sort  string `json:"s"`
+	Value string `json:"v"`
+	ID    int    `json:"i"`
+}
+
+// Paging is the paging metadata returned next to a list.
+type Paging struct {
+	Limit      int    `json:"limit"`
+	HasMore    bool   `json:"has_more"`
+	NextCursor string `json:"next_cursor"`
+}
+
+// Encode turns the cursor into an opaque string.
+func (c Cursor) Encode() string {
+	raw, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// Decode parses an opaque cursor string.
+func Decode(s string) (Cursor, error) {
+	var c Cursor
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, apperror.Field("cursor", "CURSOR_INVALID")
+	}
+	if err := json.Unmarshal(raw, &c); err != nil {
+		return c, apperror.Field("cursor", "CURSOR_INVALID")
+	}
+	return c, nil
+}
+
+// ParseLimit reads the limit query value and keeps it within bounds.
+func ParseLimit(s string) (int, error) {
+	if s == "" {
+		return DefaultLimit, nil
+	}
+	limit, err := strconv.Atoi(s)
+	if err != nil || limit < 1 {
+		return 0, apperror.Field("limit", "FIELD_POSITIVE")
+	}
+	if limit > MaxLimit {
+		limit = MaxLimit
+	}
+	return limit, nil
+}
@@ -0,0 +1,160 @@
+package validation
+
+import (
+	"errors"
+	"html"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"task-5-pbi-btpns-arthagusfiputra/helpers/apperror"
+	"task-5-pbi-btpns-arthagusfiputra/helpers/i18n"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/go-playground/validator/v10/non-standard/validators"
+)
+
+// Password length bounds. bcrypt ignores everything past 72 bytes.
+const (
+	MinPasswordLength = 8
+	MaxPasswordBytes  = 72
+)
+
+var validate = newValidator()
+
+// newValidator returns a validator that names fields after their JSON keys and knows the custom rules:
+//
+//	notblank   not empty once surrounding spaces are trimmed
+//	password   8 to 72 bytes with at least one letter and one digit
+//	httpurl    an absolute http or https URL
+//	locale     a locale with an i18n catalog
+//	escapedmax at most N characters once HTML-escaped, as stored by the models
+func newValidator() *validator.Validate {
+	v := validator.New()
+	v.RegisterTagNameFunc(jsonName)
+
+	rules := map[string]validator.Func{
+		"notblank":   validators.NotBlank,
+		"password":   isPassword,
+		"httpurl":    isHTTPURL,
+		"locale":     isLocale,
+		"escapedmax": isEscapedMax,
+	}
+	for tag, rule := range rules {
+		if err := v.RegisterValidation(tag, rule); err != nil {
+			panic(err)
+		}
+	}
+	return v
+}
+
+// Struct checks the validate tags of the request DTO s and reports every failing field at once.
+func Struct(s interface{}) error {
+	err := validate.Struct(s)
+	var failures validator.ValidationErrors
+	if !errors.As(err, &failures) {
+		return err
+	}
+
+	details := make([]apperror.FieldError, 0, len(failures))
+	for _, failure := range failures {
+		details = append(details, describe(s, failure))
+	}
+	return apperror.Fields(details)
+}
+
+// describe turns a failed rule into a field error with a catalog key.
+func describe(s interface{}, failure validator.FieldError) apperror.FieldError {
+	detail := apperror.FieldError{Field: failure.Field(), Params: i18n.Params{"field": failure.Field()}}
+	switch failure.Tag() {
+	case "required", "notblank":
+		detail.Key = "FIELD_REQUIRED"
+	case "required_without":
+		detail.Key = "FIELD_REQUIRED_WITHOUT"
+		detail.Params["other"] = otherField(s, failure.Param())
+	case "min":
+		detail.Key = "FIELD_TOO_SHORT"
+		detail.Params["min"] = failure.Param()
+	case "max", "escapedmax":
+		detail.Key = "FIELD_TOO_LONG"
+		detail.Params["max"] = failure.Param()
+	case "oneof":
+		detail.Key = "FIELD_ONE_OF"
+		detail.Params["values"] = strings.Join(strings.Fields(failure.Param()), ", ")
+	case "locale":
+		detail.Key = "FIELD_ONE_OF"
+		detail.Params["values"] = strings.Join(i18n.Locales(), ", ")
+	case "email":
+		detail.Key = "FIELD_EMAIL"
+	case "httpurl":
+		detail.Key = "FIELD_URL"
+	case "password":
+		detail.Key = "FIELD_PASSWORD"
+		detail.Params["min"] = MinPasswordLength
+		detail.Params["max"] = MaxPasswordBytes
+	case "isdefault":
+		detail.Key = "FIELD_READ_ONLY"
+	default:
+		detail.Key = "FIELD_INVALID"
+	}
+	return detail
+}
+
+// otherField returns the JSON name of the Go field name referenced by a rule parameter.
+func otherField(s interface{}, name string) string {
+	t := reflect.TypeOf(s)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if field, ok := t.FieldByName(name); ok {
+		if json := jsonName(field); json != "" {
+			return json
+		}
+	}
+	return name
+}
+
+// jsonName returns the key a struct field has in JSON, or nothing for ignored fields.
+func jsonName(field reflect.StructField) string {
+	name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+	if name == "-" {
+		return ""
+	}
+	if name == "" {
+		return field.Name
+	}
+	return name
+}
+
+func isPassword(fl validator.FieldLevel) bool {
+	password := fl.Field().String()
+	if utf8.RuneCountInString(password) < MinPasswordLength || len(password) > MaxPasswordBytes {
+		return false
+	}
+	var letter, digit bool
+	for _, r := range password {
+		letter = letter || unicode.IsLetter(r)
+		digit = digit || unicode.IsDigit(r)
+	}
+	return letter && digit
+}
+
+func isHTTPURL(fl validator.FieldLevel) bool {
+	u, err := url.Parse(fl.Field().String())
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+func isLocale(fl validator.FieldLevel) bool {
+	return i18n.Supported(fl.Field().String())
+}
+
+func isEscapedMax(fl validator.FieldLevel) bool {
+	max, err := strconv.Atoi(fl.Param())
+	if err != nil {
+		panic("validation: escapedmax needs a number, got " + fl.Param())
+	}
+	return utf8.RuneCountInString(html.EscapeString(strings.TrimSpace(fl.Field().String()))) <= max
+}
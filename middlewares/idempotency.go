@@ -0,0 +1,177 @@
+package middlewares
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"task-5-pbi-btpns-arthagusfiputra/helpers/apperror"
+	"task-5-pbi-btpns-arthagusfiputra/helpers/logging"
+	"task-5-pbi-btpns-arthagusfiputra/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+)
+
+// DefaultIdempotencyWindow is how long responses are kept for replay when IDEMPOTENCY_WINDOW is unset.
+const DefaultIdempotencyWindow = 24 * time.Hour
+
+// maxIdempotencyKey is the longest Idempotency-Key header accepted.
+const maxIdempotencyKey = 255
+
+// anonymousScope prefixes the request hash that keys of anonymous requests are scoped to instead of a user.
+const anonymousScope = "anonymous:"
+
+// IdempotencyWindow returns the configured time a response is kept for replay.
+func IdempotencyWindow() time.Duration {
+	if window, err := time.ParseDuration(os.Getenv("IDEMPOTENCY_WINDOW")); err == nil && window > 0 {
+		return window
+	}
+	return DefaultIdempotencyWindow
+}
+
+// Idempotency makes POST requests sent with an Idempotency-Key header safe to retry.
+// The first response for a key and user is stored for the window and replayed to retries with the same body,
+// while reusing the key for a different request is refused. Failed requests aren't stored, so their retries run again.
+// Keys sent without a login are scoped to the request itself, as there is no user to tell clients apart.
+func Idempotency(window time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := strings.TrimSpace(c.GetHeader("Idempotency-Key"))
+		if c.Request.Method != http.MethodPost || key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKey {
+			_ = c.Error(apperror.Field("Idempotency-Key", "FIELD_TOO_LONG").With("max", maxIdempotencyKey))
+			c.Abort()
+			return
+		}
+		db := c.MustGet("db").(*gorm.DB)
+
+		// Keep the body readable for the handler
+		body, err := ioutil.ReadAll(c.Request.Body)
+		if err != nil {
+			_ = c.Error(apperror.Malformed(err))
+			c.Abort()
+			return
+		}
+		c.Request.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+		userID, err := callerID(db, c.GetString("email"))
+		if err != nil {
+			_ = c.Error(err)
+			c.Abort()
+			return
+		}
+
+		sum := sha256.Sum256(append([]byte(c.Request.Method+" "+c.Request.URL.Path+"\n"), body...))
+		record := models.IdempotencyKey{
+			Key:         key,
+			UserID:      userID,
+			RequestHash: hex.EncodeToString(sum[:]),
+			ExpiresAt:   time.Now().Add(window),
+		}
+		// A retry of an anonymous request is still replayed,
+		// but another request under the same key neither collides with it nor gets its response
+		if userID == "" {
+			record.UserID = anonymousScope + record.RequestHash
+		}
+		stored, err := reserveKey(db, &record)
+		if err != nil {
+			_ = c.Error(err)
+			c.Abort()
+			return
+		}
+
+		// A retry gets the stored response, as long as it is the same request
+		if stored != nil {
+			switch {
+			case stored.RequestHash != record.RequestHash:
+				_ = c.Error(apperror.New(apperror.IdempotencyKeyReused))
+			case !stored.Done():
+				_ = c.Error(apperror.New(apperror.IdempotencyInProgress))
+			default:
+				c.Header("Idempotent-Replayed", "true")
+				c.Data(stored.Status, stored.ContentType, []byte(stored.Body))
+			}
+			c.Abort()
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		c.Next()
+		c.Writer = recorder.ResponseWriter
+
+		// Failed requests changed nothing, so the key is released for the retry
+		if len(c.Errors) > 0 || !recorder.Written() || recorder.Status() >= http.StatusInternalServerError {
+			err = db.Delete(&record).Error
+		} else {
+			err = db.Model(&record).Updates(map[string]interface{}{
+				"status":       recorder.Status(),
+				"content_type": recorder.Header().Get("Content-Type"),
+				"body":         recorder.body.String(),
+			}).Error
+		}
+		if err != nil {
+			logging.FromContext(c.Request.Context()).Warn("saving idempotency key", "key", key, "error", err)
+		}
+	}
+}
+
+// callerID returns the ID of the user logged in with email, or nothing for anonymous requests.
+func callerID(db *gorm.DB, email string) (string, error) {
+	if email == "" {
+		return "", nil
+	}
+	var user models.User
+	if err := db.Select("id").Where("email = ?", email).First(&user).Error; err != nil {
+		return "", apperror.Keyed(apperror.Unauthorized, "TOKEN_INVALID").Because(err)
+	}
+	return user.ID, nil
+}
+
+// reserveKey stores record as in progress, or returns the live record already stored for its key.
+func reserveKey(db *gorm.DB, record *models.IdempotencyKey) (*models.IdempotencyKey, error) {
+	var stored models.IdempotencyKey
+	err := db.Where("idempotency_key = ? AND user_id = ?", record.Key, record.UserID).First(&stored).Error
+	switch {
+	case err == nil && !stored.Expired(time.Now()):
+		return &stored, nil
+	case err == nil:
+		if err := db.Delete(&stored).Error; err != nil {
+			return nil, err
+		}
+	case !gorm.IsRecordNotFoundError(err):
+		return nil, err
+	}
+
+	if err := db.Create(record).Error; err != nil {
+		if apperror.IsDuplicate(err) {
+			return nil, apperror.New(apperror.IdempotencyInProgress) // A concurrent retry got the key first
+		}
+		return nil, err
+	}
+	return nil, nil
+}
+
+// responseRecorder keeps a copy of the response body written through it.
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
@@ -0,0 +1,56 @@
+package middlewares
+
+import (
+	"crypto/subtle"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"task-5-pbi-btpns-arthagusfiputra/helpers/apperror"
+	"task-5-pbi-btpns-arthagusfiputra/helpers/metrics"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Metrics counts and times every request by its route template, so /photos/1 and /photos/2 share a series.
+// Requests that match no route are grouped under "unmatched".
+func Metrics() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		status := strconv.Itoa(c.Writer.Status())
+		metrics.HTTPRequests.WithLabelValues(c.Request.Method, route, status).Inc()
+		metrics.HTTPDuration.WithLabelValues(c.Request.Method, route, status).Observe(time.Since(start).Seconds())
+	}
+}
+
+// MetricsToken returns the bearer token Prometheus must send to scrape /metrics, set with METRICS_TOKEN.
+// It is empty when unset, and every request to /metrics is refused then.
+func MetricsToken() string {
+	return strings.TrimSpace(os.Getenv("METRICS_TOKEN"))
+}
+
+// RequireToken lets through only the requests sent with the bearer token, and none when the token is empty.
+// The token is compared in constant time, so its prefix can't be guessed from the response time.
+func RequireToken(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sent := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if sent == "" {
+			_ = c.Error(apperror.Keyed(apperror.Unauthorized, "TOKEN_MISSING"))
+			c.Abort()
+			return
+		}
+		if token == "" || subtle.ConstantTimeCompare([]byte(sent), []byte(token)) != 1 {
+			_ = c.Error(apperror.Keyed(apperror.Unauthorized, "TOKEN_INVALID"))
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
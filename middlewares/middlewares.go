@@ -4,6 +4,9 @@ import (
 	"strings"
 
 	"task-5-pbi-btpns-arthagusfiputra/app/auth"
+	"task-5-pbi-btpns-arthagusfiputra/helpers/apperror"
+	"task-5-pbi-btpns-arthagusfiputra/helpers/i18n"
+	"task-5-pbi-btpns-arthagusfiputra/helpers/logging"
 
 	"github.com/gin-gonic/gin"
 )
@@ -13,17 +16,79 @@ func AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		tokenString := c.GetHeader("Authorization") // Get bearer token from request header
 		if tokenString == "" {
-			c.JSON(401, gin.H{"error": "Token not found"}) // Respond with an error if token is missing
+			_ = c.Error(apperror.Keyed(apperror.Unauthorized, "TOKEN_MISSING")) // Respond with an error if token is missing
 			c.Abort()
 			return
 		}
 
-		err := auth.ValidateToken(strings.Split(tokenString, "Bearer ")[1]) // Validate the token
+		email, err := auth.GetEmail(strings.TrimPrefix(tokenString, "Bearer ")) // Validate the token
 		if err != nil {
-			c.JSON(401, gin.H{"error": err.Error()}) // Respond with an error if token validation fails
+			_ = c.Error(apperror.Keyed(apperror.Unauthorized, "TOKEN_INVALID").Because(err)) // Respond with an error if token validation fails
 			c.Abort()
 			return
 		}
-		c.Next() // Continue to the next middleware or handler if token is valid
+		c.Set("email", email) // Remember who is calling for the handlers
+		c.Next()              // Continue to the next middleware or handler if token is valid
+	}
+}
+
+// OptionalAuthMiddleware identifies the caller when a token is sent, but lets anonymous requests through.
+func OptionalAuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tokenString := c.GetHeader("Authorization")
+		if tokenString == "" {
+			c.Next() // Anonymous request
+			return
+		}
+
+		email, err := auth.GetEmail(strings.TrimPrefix(tokenString, "Bearer "))
+		if err != nil {
+			_ = c.Error(apperror.Keyed(apperror.Unauthorized, "TOKEN_INVALID").Because(err)) // A bad token is rejected instead of silently ignored
+			c.Abort()
+			return
+		}
+		c.Set("email", email)
+		c.Next()
+	}
+}
+
+// ErrorHandler writes the error envelope for the last error recorded by the handlers.
+// Internal errors are logged and only a generic message is sent to the client.
+func ErrorHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if len(c.Errors) == 0 || c.Writer.Written() {
+			return
+		}
+
+		err := apperror.From(c.Errors.Last().Err)
+		if err.Code == apperror.Internal {
+			logging.FromContext(c.Request.Context()).Error("internal error", "method", c.Request.Method, "path", c.Request.URL.Path, "error", err)
+		}
+
+		locale := c.GetString("locale")
+		body := gin.H{
+			"status":  "Error",
+			"code":    err.Code,
+			"message": err.Message(locale),
+			"data":    nil,
+		}
+		if len(err.Details) > 0 {
+			body["details"] = err.Fields(locale)
+		}
+		c.JSON(err.Status(), body)
+	}
+}
+
+// Locale picks the response language from the Accept-Language header.
+// Handlers may switch to the language preferred by the logged in user before they respond.
+func Locale() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		locale := i18n.Negotiate(c.GetHeader("Accept-Language"))
+		c.Set("locale", locale)
+		c.Header("Content-Language", locale)
+		c.Header("Vary", "Accept-Language")
+		c.Next()
 	}
 }
@@ -0,0 +1,56 @@
+package models
+
+import (
+	"html"
+	"strings"
+	"task-5-pbi-btpns-arthagusfiputra/app"
+	"time"
+)
+
+// Visibility levels shared by albums and photos.
+const (
+	VisibilityPublic   = "public"
+	VisibilityUnlisted = "unlisted"
+	VisibilityPrivate  = "private"
+
+	// VisibilityFollowers is only available for photos.
+	VisibilityFollowers = "followers"
+)
+
+// Album represents a named group of photos.
+type Album struct {
+	ID           int       `gorm:"primary_key;auto_increment" json:"id"`
+	Title        string    `gorm:"size:255;not null" json:"title"`
+	Description  string    `gorm:"size:255" json:"description"`
+	CoverPhotoID *int      `json:"cover_photo_id"`
+	Visibility   string    `gorm:"size:20;not null;default:'public'" json:"visibility"`
+	UserID       string    `gorm:"not null;index" json:"user_id"`
+	Owner        app.Owner `gorm:"-" json:"owner"`
+	Photos       []Photo   `gorm:"-" json:"photos"`
+	CreatedAt    time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+	UpdatedAt    time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"updated_at"`
+}
+
+// AlbumPhoto represents the membership of a photo in an album.
+type AlbumPhoto struct {
+	AlbumID  int `gorm:"primary_key;auto_increment:false" json:"album_id"`
+	PhotoID  int `gorm:"primary_key;auto_increment:false" json:"photo_id"`
+	Position int `gorm:"not null" json:"position"`
+}
+
+// ALBUM METHODS
+
+// Init initializes Album data.
+func (a *Album) Init() {
+	a.Title = html.EscapeString(strings.TrimSpace(a.Title)) // Escape string
+	a.Description = html.EscapeString(strings.TrimSpace(a.Description))
+	a.Visibility = strings.ToLower(strings.TrimSpace(a.Visibility))
+	if a.Visibility == "" {
+		a.Visibility = VisibilityPublic
+	}
+}
+
+// VisibleTo reports whether the album can be opened by the given user ID (empty for anonymous).
+func (a *Album) VisibleTo(userID string) bool {
+	return a.Visibility != VisibilityPrivate || a.UserID == userID
+}
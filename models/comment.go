@@ -0,0 +1,29 @@
+package models
+
+import (
+	"html"
+	"strings"
+	"task-5-pbi-btpns-arthagusfiputra/app"
+	"time"
+)
+
+// Comment represents a comment on a photo. Replies point at a top-level comment through ParentID.
+type Comment struct {
+	ID        int        `gorm:"primary_key;auto_increment" json:"id"`
+	PhotoID   int        `gorm:"not null;index" json:"photo_id"`
+	UserID    string     `gorm:"not null;index" json:"user_id"`
+	ParentID  *int       `gorm:"index" json:"parent_id"`
+	Body      string     `gorm:"type:text;not null" json:"body"`
+	Author    app.Owner  `gorm:"-" json:"author"`
+	Replies   []Comment  `gorm:"-" json:"replies,omitempty"`
+	EditedAt  *time.Time `json:"edited_at"`
+	CreatedAt time.Time  `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+	UpdatedAt time.Time  `gorm:"default:CURRENT_TIMESTAMP" json:"updated_at"`
+}
+
+// COMMENT METHODS
+
+// Init initializes Comment data.
+func (cm *Comment) Init() {
+	cm.Body = html.EscapeString(strings.TrimSpace(cm.Body)) // Escape string
+}
@@ -0,0 +1,27 @@
+package models
+
+import "github.com/jinzhu/gorm"
+
+// afterCommitKey holds the work deferred until the transaction of a handle commits.
+const afterCommitKey = "models:after_commit"
+
+// OnCommit returns a handle whose transaction collects the work passed to AfterCommit,
+// and a function that runs that work. The caller runs it once the transaction has committed,
+// and drops it when the transaction rolls back.
+func OnCommit(db *gorm.DB) (*gorm.DB, func()) {
+	queue := &[]func(){}
+	return db.Set(afterCommitKey, queue), func() {
+		for _, fn := range *queue {
+			fn()
+		}
+	}
+}
+
+// AfterCommit runs fn once the transaction db belongs to has committed, or right away when there is none.
+func AfterCommit(db *gorm.DB, fn func()) {
+	if queue, ok := db.Get(afterCommitKey); ok {
+		*queue.(*[]func()) = append(*queue.(*[]func()), fn)
+		return
+	}
+	fn()
+}
@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// Follow represents a user following another user. A user can follow another user only once.
+type Follow struct {
+	ID         int       `gorm:"primary_key;auto_increment" json:"id"`
+	FollowerID string    `gorm:"not null;unique_index:idx_follows_pair" json:"follower_id"`
+	FolloweeID string    `gorm:"not null;unique_index:idx_follows_pair;index" json:"followee_id"`
+	CreatedAt  time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+}
@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// IdempotencyKey remembers the response to a request sent with an Idempotency-Key header, so retries can be replayed.
+// Keys are scoped to the user who sent them; anonymous requests, having no user, to their request hash.
+type IdempotencyKey struct {
+	ID          int       `gorm:"primary_key;auto_increment"`
+	Key         string    `gorm:"column:idempotency_key;size:255;not null;unique_index:idx_idempotency_user_key"`
+	UserID      string    `gorm:"not null;unique_index:idx_idempotency_user_key"`
+	RequestHash string    `gorm:"size:64;not null"`
+	Status      int       `gorm:"not null"`
+	ContentType string    `gorm:"size:100"`
+	Body        string    `gorm:"type:text"`
+	ExpiresAt   time.Time `gorm:"not null;index"`
+	CreatedAt   time.Time `gorm:"default:CURRENT_TIMESTAMP"`
+}
+
+// IDEMPOTENCY KEY METHODS
+
+// Done reports whether the first request with the key has finished and its response was stored.
+func (k *IdempotencyKey) Done() bool {
+	return k.Status != 0
+}
+
+// Expired reports whether the key may be used again for a new request.
+func (k *IdempotencyKey) Expired(now time.Time) bool {
+	return !now.Before(k.ExpiresAt)
+}
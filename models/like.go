@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// Like represents a user liking a photo. A user can like a photo only once.
+type Like struct {
+	ID        int       `gorm:"primary_key;auto_increment" json:"id"`
+	PhotoID   int       `gorm:"not null;unique_index:idx_likes_photo_user" json:"photo_id"`
+	UserID    string    `gorm:"not null;unique_index:idx_likes_photo_user;index" json:"user_id"`
+	CreatedAt time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+}
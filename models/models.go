@@ -1,38 +1,57 @@
 package models
 
 import (
-	"errors"
 	"html"
 	"strings"
 	"task-5-pbi-btpns-arthagusfiputra/app"
+	"task-5-pbi-btpns-arthagusfiputra/app/search"
+	"task-5-pbi-btpns-arthagusfiputra/app/storage"
 	"task-5-pbi-btpns-arthagusfiputra/helpers/hash"
+	"task-5-pbi-btpns-arthagusfiputra/helpers/metrics"
 	"time"
 
-	"github.com/badoux/checkmail"
 	"github.com/google/uuid"
+	"github.com/jinzhu/gorm"
 )
 
 // User represents the user model.
 type User struct {
-	ID        string    `gorm:"primary_key; unique" json:"id"`
-	Username  string    `gorm:"size:255;not null;" json:"username"`
-	Email     string    `gorm:"size:255;not null; unique" json:"email"`
-	Password  string    `gorm:"size:255;not null;" json:"password"`
-	Photos    Photo     `gorm:"constraint:OnUpdate:CASCADE, OnDelete:SET NULL;" json:"photos"`
-	CreatedAt time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
-	UpdatedAt time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"updated_at"`
+	ID        string     `gorm:"primary_key; unique" json:"id"`
+	Username  string     `gorm:"size:255;not null;" json:"username"`
+	Email     string     `gorm:"size:255;not null; unique" json:"email"`
+	Password  string     `gorm:"size:255;not null;" json:"-"`
+	Locale    string     `gorm:"size:10" json:"locale"`
+	Version   int        `gorm:"not null;default:1" json:"-"`
+	Admin     bool       `gorm:"not null;default:false" json:"-"`
+	Photos    Photo      `gorm:"constraint:OnUpdate:CASCADE, OnDelete:SET NULL;" json:"photos"`
+	CreatedAt time.Time  `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+	UpdatedAt time.Time  `gorm:"default:CURRENT_TIMESTAMP" json:"updated_at"`
+	DeletedAt *time.Time `gorm:"index" json:"-"`
 }
 
 // Photo represents the photo model.
 type Photo struct {
-	ID       int       `gorm:"primary_key;auto_increment" json:"id"`
-	Title    string    `gorm:"size:255;not null" json:"title"`
-	Caption  string    `gorm:"size:255;not null" json:"caption"`
-	PhotoUrl string    `gorm:"size:255;not null;" json:"photo_url"`
-	UserID   string    `gorm:"not null" json:"user_id"`
-	Owner    app.Owner `gorm:"owner"`
+	ID         int        `gorm:"primary_key;auto_increment" json:"id"`
+	Title      string     `gorm:"size:255;not null;index" json:"title"`
+	Caption    string     `gorm:"size:255;not null" json:"caption"`
+	PhotoUrl   string     `gorm:"size:255;not null;" json:"photo_url"`
+	FileKey    string     `gorm:"size:255" json:"-"`
+	SourceURL  string     `gorm:"-" json:"source_url,omitempty"`
+	Tags       []string   `gorm:"-" json:"tags"`
+	LikeCount  int        `gorm:"not null;default:0" json:"like_count"`
+	LikedByMe  bool       `gorm:"-" json:"liked_by_me"`
+	UserID     string     `gorm:"not null" json:"user_id"`
+	Visibility string     `gorm:"size:20;not null;default:'public';index" json:"visibility"`
+	Version    int        `gorm:"not null;default:1" json:"version"`
+	Owner      app.Owner  `gorm:"owner"`
+	CreatedAt  time.Time  `gorm:"default:CURRENT_TIMESTAMP;index" json:"created_at"`
+	UpdatedAt  time.Time  `gorm:"default:CURRENT_TIMESTAMP" json:"updated_at"`
+	DeletedAt  *time.Time `gorm:"index" json:"-"`
 }
 
+// PhotoIndex is the in-memory full-text index used when the database has no native full-text search.
+var PhotoIndex = search.NewIndex()
+
 // USER METHODS
 
 // Init initializes user data.
@@ -40,10 +59,13 @@ func (u *User) Init() {
 	u.ID = uuid.New().String()                                    // Generate a new UUID
 	u.Username = html.EscapeString(strings.TrimSpace(u.Username)) // Escape string
 	u.Email = html.EscapeString(strings.TrimSpace(u.Email))
+	u.Locale = strings.ToLower(strings.TrimSpace(u.Locale))
+	u.Version = 1
 }
 
 // HashPassword changes the password to a hashed password.
 func (u *User) HashPassword() error {
+	defer metrics.TimePassword("hash")()
 	hashedPassword, err := hash.HashPassword(u.Password)
 	if err != nil {
 		return err
@@ -54,6 +76,7 @@ func (u *User) HashPassword() error {
 
 // CheckPassword checks the provided password.
 func (u *User) CheckPassword(providedPassword string) error {
+	defer metrics.TimePassword("compare")()
 	err := hash.CheckPasswordHash(u.Password, providedPassword)
 	if err != nil {
 		return err
@@ -61,55 +84,27 @@ func (u *User) CheckPassword(providedPassword string) error {
 	return nil
 }
 
-// Validate validates user data based on the given action.
-func (u *User) Validate(action string) error {
-	switch strings.ToLower(action) {
-	case "login":
-		if u.Email == "" {
-			return errors.New("email is required")
-		}
-		if u.Password == "" {
-			return errors.New("password is required")
-		}
-		if err := checkmail.ValidateFormat(u.Email); err != nil {
-			return errors.New("email is invalid")
-		}
-		return nil
-
-	case "register":
-		if u.ID == "" {
-			return errors.New("id is required")
-		} else if u.Email == "" {
-			return errors.New("email is required")
-		} else if err := checkmail.ValidateFormat(u.Email); err != nil {
-			return errors.New("email is invalid")
-		} else if u.Username == "" {
-			return errors.New("username is required")
-		} else if u.Password == "" {
-			return errors.New("password is required")
-		} else if len(u.Password) < 8 {
-			return errors.New("password must be at least 8 characters")
-		}
-		return nil
-
-	case "update":
-		if u.ID == "" {
-			return errors.New("id is required")
-		} else if u.Email == "" {
-			return errors.New("email is required")
-		} else if err := checkmail.ValidateFormat(u.Email); err != nil {
-			return errors.New("invalid email")
-		} else if u.Username == "" {
-			return errors.New("username is required")
-		} else if u.Password == "" {
-			return errors.New("password is required")
-		} else if len(u.Password) < 8 {
-			return errors.New("password must be at least 8 characters")
-		}
-		return nil
+// SelfView returns the full view of the user, shown only to the user themself.
+func (u *User) SelfView(primary *Photo, stats app.UserStats) app.UserSelf {
+	return app.UserSelf{
+		ID:           u.ID,
+		Username:     u.Username,
+		Email:        u.Email,
+		Locale:       u.Locale,
+		PrimaryPhoto: primary.View(),
+		Stats:        stats,
+		CreatedAt:    u.CreatedAt,
+		UpdatedAt:    u.UpdatedAt,
+	}
+}
 
-	default:
-		return nil
+// PublicView returns the view of the user that anyone may see.
+func (u *User) PublicView(primary *Photo, stats app.UserStats) app.UserPublic {
+	return app.UserPublic{
+		ID:           u.ID,
+		Username:     u.Username,
+		PrimaryPhoto: primary.View(),
+		Stats:        stats,
 	}
 }
 
@@ -120,32 +115,52 @@ func (p *Photo) Init() {
 	p.Title = html.EscapeString(strings.TrimSpace(p.Title)) // Escape string
 	p.Caption = html.EscapeString(strings.TrimSpace(p.Caption))
 	p.PhotoUrl = html.EscapeString(strings.TrimSpace(p.PhotoUrl))
+	p.SourceURL = strings.TrimSpace(p.SourceURL)
+	p.LikeCount = 0 // Only likes change the count
+	p.Version = 1
+	p.Visibility = strings.ToLower(strings.TrimSpace(p.Visibility))
+	if p.Visibility == "" {
+		p.Visibility = VisibilityPublic
+	}
 }
 
-// Validate validates Photo data based on the given action.
-func (p *Photo) Validate(action string) error {
-	switch strings.ToLower(action) {
-	case "upload":
-		if p.Title == "" {
-			return errors.New("title is required")
-		} else if p.Caption == "" {
-			return errors.New("caption is required")
-		} else if p.UserID == "" {
-			return errors.New("userID is required")
-		}
-		return nil
+// SignURL points PhotoUrl at a signed, expiring download URL when the file is kept in our storage.
+func (p *Photo) SignURL() {
+	if p.FileKey != "" {
+		p.PhotoUrl = storage.SignURL(p.FileKey, storage.URLTTL())
+	}
+}
 
-	case "change":
-		if p.Title == "" {
-			return errors.New("title is required")
-		} else if p.Caption == "" {
-			return errors.New("caption is required")
-		} else if p.PhotoUrl == "" {
-			return errors.New("photoUrl is required")
-		}
+// View returns the public fields of the photo, or nil for a missing photo.
+func (p *Photo) View() *app.Photo {
+	if p == nil {
 		return nil
+	}
+	p.SignURL()
+	return &app.Photo{
+		Title:    p.Title,
+		Caption:  p.Caption,
+		PhotoUrl: p.PhotoUrl,
+	}
+}
 
-	default:
-		return nil
+// AfterSave keeps the in-memory search index in step with the photo once the write has committed,
+// so a rolled back write leaves the index as it was.
+func (p *Photo) AfterSave(db *gorm.DB) {
+	saved := *p
+	AfterCommit(db, saved.Index)
+}
+
+// Index adds the photo to the in-memory search index once it has been loaded.
+// Writes that skip the hooks, such as UpdateColumn, call it themselves.
+func (p *Photo) Index() {
+	if PhotoIndex.Ready() {
+		PhotoIndex.Add(p.ID, html.UnescapeString(p.Title), html.UnescapeString(p.Caption))
 	}
 }
+
+// AfterDelete removes the photo from the in-memory search index once the delete has committed.
+func (p *Photo) AfterDelete(db *gorm.DB) {
+	id := p.ID
+	AfterCommit(db, func() { PhotoIndex.Remove(id) })
+}
@@ -0,0 +1,56 @@
+package models
+
+import (
+	"task-5-pbi-btpns-arthagusfiputra/app"
+	"task-5-pbi-btpns-arthagusfiputra/app/events"
+	"task-5-pbi-btpns-arthagusfiputra/helpers/i18n"
+	"time"
+)
+
+// Notification tells a user that others interacted with them or their content.
+// Unread notifications of the same kind about the same subject are coalesced into one.
+type Notification struct {
+	ID         int        `gorm:"primary_key;auto_increment" json:"id"`
+	UserID     string     `gorm:"not null;index" json:"-"`
+	Type       string     `gorm:"size:30;not null" json:"type"`
+	PhotoID    *int       `json:"photo_id"`
+	CommentID  *int       `json:"comment_id"`
+	ActorID    string     `gorm:"not null" json:"-"`
+	ActorCount int        `gorm:"not null;default:1" json:"actor_count"`
+	ReadAt     *time.Time `json:"read_at"`
+	Actor      app.Owner  `gorm:"-" json:"actor"`
+	Message    string     `gorm:"-" json:"message"`
+	CreatedAt  time.Time  `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+	UpdatedAt  time.Time  `gorm:"default:CURRENT_TIMESTAMP;index" json:"updated_at"`
+}
+
+// NotificationActor records who took part in a coalesced notification, so repeated actions count once.
+type NotificationActor struct {
+	NotificationID int    `gorm:"primary_key;auto_increment:false"`
+	UserID         string `gorm:"primary_key"`
+}
+
+// NOTIFICATION METHODS
+
+// Describe fills the message shown for the notification in the given locale, naming the latest actor.
+func (n *Notification) Describe(locale string) {
+	var key string
+	switch n.Type {
+	case events.PhotoLiked:
+		key = "NOTIFICATION_PHOTO_LIKED"
+	case events.PhotoCommented:
+		key = "NOTIFICATION_PHOTO_COMMENTED"
+	case events.CommentReplied:
+		key = "NOTIFICATION_COMMENT_REPLIED"
+	case events.UserFollowed:
+		key = "NOTIFICATION_USER_FOLLOWED"
+	default:
+		return
+	}
+
+	if n.ActorCount > 1 {
+		n.Message = i18n.T(locale, key+"_MANY", i18n.Params{"count": n.ActorCount})
+	} else {
+		n.Message = i18n.T(locale, key, i18n.Params{"actor": n.Actor.Username})
+	}
+}
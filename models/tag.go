@@ -0,0 +1,68 @@
+package models
+
+import (
+	"strings"
+	"task-5-pbi-btpns-arthagusfiputra/helpers/apperror"
+	"unicode"
+)
+
+// Tag limits.
+const (
+	MaxTagLength    = 50
+	MaxTagsPerPhoto = 20
+)
+
+// Tag represents a normalized tag name with the number of photos using it.
+type Tag struct {
+	ID         int    `gorm:"primary_key;auto_increment" json:"id"`
+	Name       string `gorm:"size:50;not null;unique_index" json:"name"`
+	UsageCount int    `gorm:"not null;default:0" json:"usage_count"`
+}
+
+// PhotoTag represents a tag attached to a photo.
+type PhotoTag struct {
+	PhotoID int `gorm:"primary_key;auto_increment:false" json:"photo_id"`
+	TagID   int `gorm:"primary_key;auto_increment:false;index" json:"tag_id"`
+}
+
+// NormalizeTag case-folds a tag name and strips a leading '#' and surrounding spaces.
+// Inner spaces become dashes so "Street Photo" and "street-photo" are the same tag.
+func NormalizeTag(name string) string {
+	name = strings.TrimPrefix(strings.TrimSpace(name), "#")
+	name = strings.ToLower(strings.Join(strings.Fields(name), "-"))
+	return name
+}
+
+// NormalizeTags normalizes and deduplicates tag names, keeping their order.
+func NormalizeTags(names []string) ([]string, error) {
+	seen := make(map[string]bool, len(names))
+	tags := make([]string, 0, len(names))
+	for _, name := range names {
+		tag := NormalizeTag(name)
+		if tag == "" || seen[tag] {
+			continue
+		}
+		if err := validateTag(tag); err != nil {
+			return nil, err
+		}
+		seen[tag] = true
+		tags = append(tags, tag)
+	}
+	if len(tags) > MaxTagsPerPhoto {
+		return nil, apperror.Field("tags", "TAGS_TOO_MANY").With("max", MaxTagsPerPhoto)
+	}
+	return tags, nil
+}
+
+// validateTag checks the length and characters of a normalized tag.
+func validateTag(tag string) error {
+	if len([]rune(tag)) > MaxTagLength {
+		return apperror.Field("tags", "TAG_TOO_LONG").With("max", MaxTagLength)
+	}
+	for _, r := range tag {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '-' && r != '_' {
+			return apperror.Field("tags", "TAG_INVALID")
+		}
+	}
+	return nil
+}
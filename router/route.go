@@ -1,37 +1,158 @@
 package router
 
 import (
+	"os"
+	"time"
+
+	"task-5-pbi-btpns-arthagusfiputra/app/events"
+	"task-5-pbi-btpns-arthagusfiputra/app/storage"
+	"task-5-pbi-btpns-arthagusfiputra/app/stream"
 	"task-5-pbi-btpns-arthagusfiputra/controllers"
+	"task-5-pbi-btpns-arthagusfiputra/helpers/apperror"
+	"task-5-pbi-btpns-arthagusfiputra/helpers/logging"
+	"task-5-pbi-btpns-arthagusfiputra/helpers/metrics"
 	"task-5-pbi-btpns-arthagusfiputra/middlewares"
 
 	"github.com/gin-gonic/gin"
 	"github.com/jinzhu/gorm"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 // InitRoutes initializes the API routes and returns a Gin engine.
 func InitRoutes(db *gorm.DB) *gin.Engine {
-	// Create a new Gin router with default middleware
-	router := gin.Default()
+	// Structured JSON logs on stdout; SQL statements are only logged with LOG_LEVEL=debug
+	logger := logging.Setup()
+	db = logging.SQL(db, logger)
+
+	// Query durations and connection pool stats for /metrics
+	if err := metrics.InstrumentDB(db); err != nil {
+		logger.Error("registering database metrics", "error", err)
+	}
+
+	// Create a new Gin router; logging and recovery are set up below
+	router := gin.New()
+
+	// Storage for the uploaded photo files, served through signed URLs that anyone could forge without a secret
+	store := storage.Default()
+	if err := storage.CheckURLKey(); err != nil {
+		logger.Error("signing file urls", "error", err)
+		os.Exit(1)
+	}
+
+	// Events published by the handlers, recorded as notifications and streamed to clients
+	bus := events.New()
+	hub := stream.NewHub(64, 1024)
+	bus.Subscribe(controllers.RecordNotifications(db, bus))
+	bus.Subscribe(controllers.ForwardEvents(db, hub))
+
+	// Deleted users and photos are removed for good once the retention period is over
+	go controllers.SchedulePurge(db, store, controllers.Retention(), time.Hour)
 
-	// Middleware to set the database connection as a context variable
+	// Middleware to tag each request with an ID and log it once answered
+	router.Use(middlewares.RequestID(logger), middlewares.AccessLog(), middlewares.Metrics())
+
+	// Middleware to answer in the language of the client
+	router.Use(middlewares.Locale())
+
+	// Middleware to render the errors reported by the handlers
+	router.Use(middlewares.ErrorHandler())
+
+	// Middleware to report panics as internal errors
+	router.Use(middlewares.Recovery())
+
+	// Middleware to set the database connection, the storage, the event bus and the stream hub as context variables
 	router.Use(func(c *gin.Context) {
-		c.Set("db", db)
+		c.Set("db", logging.SQL(db, logging.FromContext(c.Request.Context())))
+		c.Set("storage", store)
+		c.Set("events", bus)
+		c.Set("stream", hub)
+	})
+
+	// Retried POST requests with the same Idempotency-Key get the first response again
+	idempotency := middlewares.Idempotency(middlewares.IdempotencyWindow())
+
+	// Unknown routes get the same error envelope
+	router.HandleMethodNotAllowed = true
+	router.NoRoute(func(c *gin.Context) {
+		_ = c.Error(apperror.Keyed(apperror.NotFound, "ROUTE_NOT_FOUND"))
+	})
+	router.NoMethod(func(c *gin.Context) {
+		_ = c.Error(apperror.New(apperror.MethodNotAllowed))
 	})
 
 	// User Routes
-	router.POST("/users/login", controllers.Login)          // Route for user login
-	router.POST("/users/register", controllers.CreateUser)  // Route for user registration
-	router.PUT("/users/:userId", controllers.UpdateUser)    // Route to update user information
-	router.DELETE("/users/:userId", controllers.DeleteUser) // Route to delete a user account
+	router.POST("/users/login", controllers.Login)                      // Route for user login
+	router.POST("/users/register", idempotency, controllers.CreateUser) // Route for user registration
+	router.GET("/users/:userId", controllers.GetUser)                   // Route to retrieve the public profile of a user
 
-	router.GET("/photos", controllers.GetPhoto) // Route to retrieve photos
+	// Metrics Routes, only served to a scraper holding METRICS_TOKEN
+	metricsToken := middlewares.MetricsToken()
+	if metricsToken == "" {
+		logger.Info("METRICS_TOKEN is unset, every request to /metrics is refused")
+	}
+	router.GET("/metrics", middlewares.RequireToken(metricsToken), gin.WrapH(promhttp.Handler())) // Route for Prometheus to scrape the metrics
+
+	// File Routes
+	router.GET("/files/*key", controllers.ServeFile) // Route to download a stored photo file with a signed URL
+
+	// Read routes identify the caller when a token is sent, so visibility can be applied
+	public := router.Group("/").Use(middlewares.OptionalAuthMiddleware())
+	{
+		public.GET("/photos", controllers.GetPhoto)                           // Route to retrieve photos
+		public.GET("/photos/:photoId", controllers.GetPhotoByID)              // Route to retrieve a single photo
+		public.GET("/users/:userId/photos", controllers.GetUserPhotos)        // Route to retrieve the photos of a user
+		public.GET("/photos/:photoId/likes", controllers.GetPhotoLikes)       // Route to list the users who liked a photo
+		public.GET("/photos/:photoId/comments", controllers.GetPhotoComments) // Route to list the comments of a photo
+
+		public.GET("/tags", controllers.GetTags)               // Route to autocomplete tags
+		public.GET("/search/photos", controllers.SearchPhotos) // Route to search photos by title and caption
+
+		public.GET("/albums", controllers.GetAlbums)         // Route to retrieve public albums
+		public.GET("/albums/:albumId", controllers.GetAlbum) // Route to retrieve an album with its photos
+
+		public.GET("/users/:userId/followers", controllers.GetFollowers) // Route to list the followers of a user
+		public.GET("/users/:userId/following", controllers.GetFollowing) // Route to list the users a user follows
+	}
 
 	// Middlewares for photo related routes
-	authorized := router.Group("/").Use(middlewares.AuthMiddleware()) // Group of routes requiring authentication
+	authorized := router.Group("/").Use(middlewares.AuthMiddleware(), idempotency) // Group of routes requiring authentication
 	{
-		authorized.POST("/photos", controllers.CreatePhoto)            // Route to create a new photo (authentication required)
-		authorized.PUT("/photos/:photoId", controllers.UpdatePhoto)    // Route to update a photo (authentication required)
-		authorized.DELETE("/photos/:photoId", controllers.DeletePhoto) // Route to delete a photo (authentication required)
+		authorized.GET("/users/me", controllers.GetMe)                   // Route to retrieve the profile of the logged in user
+		authorized.PUT("/users/me/password", controllers.ChangePassword) // Route to change the password of the logged in user
+		authorized.PUT("/users/:userId", controllers.UpdateUser)         // Route to update the logged in user
+		authorized.PATCH("/users/:userId", controllers.PatchUser)        // Route to partially update the logged in user
+		authorized.DELETE("/users/:userId", controllers.DeleteUser)      // Route to delete a user account (own account, or any as administrator)
+		authorized.GET("/feed", controllers.GetFeed)                     // Route to retrieve the photos of followed users
+
+		authorized.POST("/users/:userId/follow", controllers.FollowUser)     // Route to follow a user
+		authorized.DELETE("/users/:userId/follow", controllers.UnfollowUser) // Route to unfollow a user
+
+		authorized.GET("/events", controllers.StreamEvents) // Route to receive photo and notification events as Server-Sent Events
+
+		authorized.GET("/notifications", controllers.GetNotifications)                          // Route to retrieve the notifications of the logged in user
+		authorized.PUT("/notifications/read", controllers.MarkAllNotificationsRead)             // Route to mark every notification as read
+		authorized.PUT("/notifications/:notificationId/read", controllers.MarkNotificationRead) // Route to mark a notification as read
+
+		authorized.POST("/photos", controllers.CreatePhoto)                 // Route to create a new photo (authentication required)
+		authorized.PUT("/photos/:photoId", controllers.UpdatePhoto)         // Route to update a photo (authentication required)
+		authorized.PATCH("/photos/:photoId", controllers.PatchPhoto)        // Route to partially update a photo
+		authorized.DELETE("/photos/:photoId", controllers.DeletePhoto)      // Route to delete a photo (authentication required)
+		authorized.POST("/photos/:photoId/like", controllers.LikePhoto)     // Route to like a photo
+		authorized.DELETE("/photos/:photoId/like", controllers.UnlikePhoto) // Route to unlike a photo
+
+		authorized.POST("/photos/:photoId/comments", controllers.CreateComment)              // Route to comment on a photo
+		authorized.PUT("/photos/:photoId/comments/:commentId", controllers.UpdateComment)    // Route to edit a comment
+		authorized.DELETE("/photos/:photoId/comments/:commentId", controllers.DeleteComment) // Route to delete a comment
+
+		authorized.POST("/albums", controllers.CreateAlbum)                                 // Route to create an album
+		authorized.PUT("/albums/:albumId", controllers.UpdateAlbum)                         // Route to update an album
+		authorized.DELETE("/albums/:albumId", controllers.DeleteAlbum)                      // Route to delete an album
+		authorized.POST("/albums/:albumId/photos", controllers.AddAlbumPhoto)               // Route to add a photo to an album
+		authorized.PUT("/albums/:albumId/photos", controllers.ReorderAlbumPhotos)           // Route to reorder the photos of an album
+		authorized.DELETE("/albums/:albumId/photos/:photoId", controllers.RemoveAlbumPhoto) // Route to remove a photo from an album
+
+		authorized.POST("/admin/users/:userId/restore", controllers.RestoreUser)    // Route to restore a deleted user (administrators only)
+		authorized.POST("/admin/photos/:photoId/restore", controllers.RestorePhoto) // Route to restore a deleted photo (administrators only)
 	}
 
 	return router